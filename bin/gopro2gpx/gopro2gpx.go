@@ -1,21 +1,23 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
-	"time"
 
+	"github.com/stilldavid/gopro-utils/bin/internal/cli"
+	"github.com/stilldavid/gopro-utils/gpmf"
 	"github.com/stilldavid/gopro-utils/telemetry"
-	"github.com/tkrajina/gpxgo/gpx"
 )
 
 func main() {
-	gpxData := new(gpx.GPX)
-
-	inName := flag.String("i", "", "Required: telemetry file to read")
+	inName := flag.String("i", "", "Required: telemetry file (.bin) or GoPro video (.MP4) to read")
 	outName := flag.String("o", "", "Required: gpx file to write")
+	device := flag.String("device", "", "Only read telemetry of the device with this ID or name, eg. 1 or Camera")
+	lenient := flag.Bool("lenient", false, "Skip corrupt data instead of stopping at it")
+	chapters := flag.Bool("chapters", false, "Also read the other files of a recording the camera split up, eg. GH020001.MP4 with GH010001.MP4, as one")
 	flag.Parse()
 
 	if *inName == "" {
@@ -23,58 +25,52 @@ func main() {
 		return
 	}
 
-	telemFile, err := os.Open(*inName)
+	names, err := cli.Inputs(*inName, *chapters)
+	if err != nil {
+		fmt.Println("Cannot look for chapters:", err)
+		os.Exit(1)
+	}
+
+	// payloads in order, carrying on from one chapter to the next
+	dec, done, err := cli.Open(names, *device, *lenient)
 	if err != nil {
-		fmt.Printf("Cannot access telemetry file %s.\n", *inName)
+		fmt.Println("Cannot read telemetry:", err)
 		os.Exit(1)
 	}
-	defer telemFile.Close()
+	defer done()
 
-	t := &telemetry.TELEM{}
-	t_prev := &telemetry.TELEM{}
+	// direction of travel, carried from one payload to the next
+	tracker := telemetry.NewTracker()
 
-	track := new(gpx.GPXTrack)
-	segment := new(gpx.GPXTrackSegment)
+	// a track for each device, eg. the camera and a drone
+	var devices cli.Devices
 
 	for {
-		t, err = telemetry.Read(telemFile)
-		if err != nil && err != io.EOF {
+		t, err := dec.Next()
+		if err == io.EOF {
+			break
+		} else if errors.Is(err, gpmf.ErrTruncated) {
+			// cut short, eg. by a dead battery: keep what was recorded
+			fmt.Println("Warning:", err)
+			if t == nil {
+				continue
+			}
+		} else if err != nil {
 			fmt.Println("Error reading telemetry file", err)
 			os.Exit(1)
-		} else if err == io.EOF || t == nil {
-			break
 		}
 
-		// first full, guess it's about a second
-		if t_prev.IsZero() {
-			*t_prev = *t
-			t.Clear()
+		// no GPS time yet, nothing to plot
+		if t.IsZero() {
 			continue
 		}
 
-		// process until t.Time
-		t_prev.FillTimes(t.Time.Time)
-		telems := t_prev.ShitJson()
-
-		for i, _ := range telems {
-			segment.AppendPoint(
-				&gpx.GPXPoint{
-					Point: gpx.Point{
-						Latitude:  telems[i].Latitude,
-						Longitude: telems[i].Longitude,
-						Elevation: *gpx.NewNullableFloat64(telems[i].Altitude),
-					},
-					Timestamp: time.Unix(telems[i].TS/1000/1000, telems[i].TS%(1000*1000)*1000),
-				},
-			)
-		}
-
-		*t_prev = *t
-		t = &telemetry.TELEM{}
+		devices.Add(t.Device, tracker.Rows(t))
 	}
 
-	track.AppendSegment(segment)
-	gpxData.AppendTrack(track)
+	for _, skip := range dec.Skipped() {
+		fmt.Printf("Skipped %d bytes at offset %d: %s\n", skip.Length, skip.Offset, skip.Err)
+	}
 
 	gpxFile, err := os.Create(*outName)
 	if err != nil {
@@ -90,6 +86,6 @@ func main() {
 		}
 	}(gpxFile)
 
-	xml, err := gpxData.ToXml(gpx.ToXmlParams{Version: "1.1", Indent: true})
+	xml, err := devices.GPX()
 	gpxFile.Write(xml)
 }
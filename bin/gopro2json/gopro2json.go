@@ -2,21 +2,53 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 
+	"github.com/stilldavid/gopro-utils/bin/internal/cli"
+	"github.com/stilldavid/gopro-utils/gpmf"
 	"github.com/stilldavid/gopro-utils/telemetry"
 )
 
 type data struct {
-	Data []telemetry.TELEM_OUT `json:"data"`
+	cli.Data
+	Orientation *orientation     `json:"orientation,omitempty"`
+	Image       *image           `json:"image,omitempty"`
+	Faces       []telemetry.FACE `json:"faces,omitempty"`
+}
+
+// camera and image orientation, for stabilization
+type orientation struct {
+	Camera  []telemetry.CORI `json:"cori"`
+	Image   []telemetry.IORI `json:"iori"`
+	Gravity []telemetry.GRAV `json:"grav"`
+}
+
+// per-frame exposure and image sensor values, for colour grading
+type image struct {
+	Shutter      []telemetry.SHUT `json:"shut"`
+	Gain         []telemetry.ISOG `json:"isog"`
+	ISO          []telemetry.ISOE `json:"isoe"`
+	WhiteBalance []telemetry.WBAL `json:"wbal"`
+	WhiteRGB     []telemetry.WRGB `json:"wrgb"`
+	Luma         []telemetry.YAVG `json:"yavg"`
+	Uniformity   []telemetry.UNIF `json:"unif"`
+	Hues         []telemetry.HUES `json:"hues"`
+	Scenes       []telemetry.SCEN `json:"scen"`
 }
 
 func main() {
-	inName := flag.String("i", "", "Required: telemetry file to read")
+	inName := flag.String("i", "", "Required: telemetry file (.bin) or GoPro video (.MP4) to read")
 	outName := flag.String("o", "", "Required: json file to write")
+	withOrientation := flag.Bool("orientation", false, "Include CORI/IORI/GRAV orientation samples")
+	withFaces := flag.Bool("faces", false, "Include per-frame face bounding boxes")
+	withImage := flag.Bool("image", false, "Include per-frame exposure, ISO, white balance, luma, hue and scene values")
+	device := flag.String("device", "", "Only read telemetry of the device with this ID or name, eg. 1 or Camera")
+	lenient := flag.Bool("lenient", false, "Skip corrupt data instead of stopping at it")
+	chapters := flag.Bool("chapters", false, "Also read the other files of a recording the camera split up, eg. GH020001.MP4 with GH010001.MP4, as one")
 	flag.Parse()
 
 	if *inName == "" {
@@ -24,42 +56,87 @@ func main() {
 		return
 	}
 
-	telemFile, err := os.Open(*inName)
+	names, err := cli.Inputs(*inName, *chapters)
+	if err != nil {
+		fmt.Println("Cannot look for chapters:", err)
+		os.Exit(1)
+	}
+
+	d := data{}
+	streams := map[string]telemetry.StreamInfo{}
+	if *withOrientation {
+		d.Orientation = &orientation{}
+	}
+	if *withImage {
+		d.Image = &image{}
+	}
+
+	// payloads in order, carrying on from one chapter to the next
+	dec, done, err := cli.Open(names, *device, *lenient)
 	if err != nil {
-		fmt.Printf("Cannot access telemetry file %s.\n", *inName)
+		fmt.Println("Cannot read telemetry:", err)
 		os.Exit(1)
 	}
-	defer telemFile.Close()
+	defer done()
 
-	var d data
+	// direction of travel, carried from one payload to the next
+	tracker := telemetry.NewTracker()
 
-	t := &telemetry.TELEM{}
-	t_prev := &telemetry.TELEM{}
+	// rows of each device, eg. the camera and a drone
+	var devices cli.Devices
 
 	for {
-		t, err = telemetry.Read(telemFile)
-		if err != nil && err != io.EOF {
+		t, err := dec.Next()
+		if err == io.EOF {
+			break
+		} else if errors.Is(err, gpmf.ErrTruncated) {
+			// cut short, eg. by a dead battery: keep what was recorded
+			fmt.Println("Warning:", err)
+			if t == nil {
+				continue
+			}
+		} else if err != nil {
 			fmt.Println("Error reading telemetry file", err)
 			os.Exit(1)
-		} else if err == io.EOF || t == nil {
-			break
 		}
 
-		// first full, guess it's about a second
-		if t_prev.IsZero() {
-			*t_prev = *t
-			t.Clear()
-			continue
+		for key, info := range t.Streams {
+			streams[key] = info
+		}
+
+		if d.Orientation != nil {
+			d.Orientation.Camera = append(d.Orientation.Camera, t.Cori...)
+			d.Orientation.Image = append(d.Orientation.Image, t.Iori...)
+			d.Orientation.Gravity = append(d.Orientation.Gravity, t.Grav...)
 		}
 
-		// process until t.Time
-		t_prev.FillTimes(t.Time.Time)
+		if *withFaces {
+			d.Faces = append(d.Faces, t.Face...)
+		}
 
-		telems := t_prev.ShitJson()
-		d.Data = append(d.Data, telems...)
+		if d.Image != nil {
+			d.Image.Shutter = append(d.Image.Shutter, t.Shut...)
+			d.Image.Gain = append(d.Image.Gain, t.Isog...)
+			d.Image.ISO = append(d.Image.ISO, t.Isoe...)
+			d.Image.WhiteBalance = append(d.Image.WhiteBalance, t.Wbal...)
+			d.Image.WhiteRGB = append(d.Image.WhiteRGB, t.Wrgb...)
+			d.Image.Luma = append(d.Image.Luma, t.Yavg...)
+			d.Image.Uniformity = append(d.Image.Uniformity, t.Unif...)
+			d.Image.Hues = append(d.Image.Hues, t.Hues...)
+			d.Image.Scenes = append(d.Image.Scenes, t.Scen...)
+		}
+
+		// no GPS time yet, nothing to report
+		if t.IsZero() {
+			continue
+		}
+
+		devices.Add(t.Device, tracker.Rows(t))
+	}
+	d.Data = devices.Data(streams)
 
-		*t_prev = *t
-		t = &telemetry.TELEM{}
+	for _, skip := range dec.Skipped() {
+		fmt.Printf("Skipped %d bytes at offset %d: %s\n", skip.Length, skip.Offset, skip.Err)
 	}
 
 	jsonFile, err := os.Create(*outName)
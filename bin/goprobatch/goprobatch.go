@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/stilldavid/gopro-utils/mp4"
+)
+
+// files taken to hold telemetry, by lower case extension
+var inputs = map[string]bool{".mp4": true, ".mov": true, ".bin": true}
+
+func main() {
+	dir := flag.String("dir", "", "Required: directory to search for GoPro videos (.MP4, .MOV) and telemetry files (.bin)")
+	formatList := flag.String("formats", formatJSON, "Comma separated outputs to write next to each file: json, gpx")
+	workers := flag.Int("workers", runtime.NumCPU(), "Number of files to process at once")
+	progressName := flag.String("progress", "", "File recording what is done, so a rerun skips it (default .goprobatch in -dir)")
+	device := flag.String("device", "", "Only read telemetry of the device with this ID or name, eg. 1 or Camera")
+	lenient := flag.Bool("lenient", false, "Skip corrupt data instead of stopping at it")
+	chapters := flag.Bool("chapters", false, "Process the files of a recording the camera split up, eg. GH010001.MP4 and GH020001.MP4, as one")
+	flag.Parse()
+
+	if *dir == "" {
+		flag.Usage()
+		return
+	}
+	if *workers < 1 {
+		*workers = 1
+	}
+	if *progressName == "" {
+		*progressName = filepath.Join(*dir, ".goprobatch")
+	}
+
+	o := options{device: *device, lenient: *lenient}
+	for _, format := range strings.Split(*formatList, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if format != formatJSON && format != formatGPX {
+			fmt.Printf("Unknown format %s.\n", format)
+			os.Exit(1)
+		}
+		o.formats = append(o.formats, format)
+	}
+
+	p, err := openProgress(*progressName)
+	if err != nil {
+		fmt.Printf("Cannot open progress file %s: %s\n", *progressName, err)
+		os.Exit(1)
+	}
+
+	defer func(p *progress) {
+		err := p.Close()
+		if err != nil {
+			fmt.Printf("Cannot close progress file %s: %s", *progressName, err)
+			os.Exit(1)
+		}
+	}(p)
+
+	// every input under dir, relative to it
+	var files []string
+	infos := map[string]os.FileInfo{}
+	err = filepath.Walk(*dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !inputs[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+
+		rel, err := filepath.Rel(*dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		infos[rel] = info
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Cannot search %s: %s\n", *dir, err)
+		os.Exit(1)
+	}
+
+	// the recordings, and those still to do
+	var recordings, todo [][]string
+	if *chapters {
+		recordings = mp4.Chapters(files)
+	} else {
+		for _, file := range files {
+			recordings = append(recordings, []string{file})
+		}
+	}
+	for _, rec := range recordings {
+		var size int64
+		var modified time.Time
+		for _, file := range rec {
+			size += infos[file].Size()
+			if infos[file].ModTime().After(modified) {
+				modified = infos[file].ModTime()
+			}
+		}
+		if !p.Done(rec[0], size, modified, o) {
+			todo = append(todo, rec)
+		}
+	}
+
+	fmt.Printf("%d recordings, %d already done.\n", len(recordings), len(recordings)-len(todo))
+
+	jobs := make(chan []string)
+	results := make(chan summary)
+
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rec := range jobs {
+				paths := make([]string, len(rec))
+				for i, rel := range rec {
+					paths[i] = filepath.Join(*dir, rel)
+				}
+
+				s := process(paths, o)
+				s.File, s.Chapters = rec[0], rec[1:]
+				results <- s
+			}
+		}()
+	}
+
+	go func() {
+		for _, rec := range todo {
+			jobs <- rec
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	// progress is only written from here, one recording at a time
+	for s := range results {
+		if s.Error != "" {
+			fmt.Printf("%s: %s\n", s.File, s.Error)
+		} else {
+			fmt.Printf("%s: done\n", s.File)
+		}
+		if err := p.Add(s); err != nil {
+			fmt.Printf("Cannot record progress in %s: %s\n", *progressName, err)
+			os.Exit(1)
+		}
+	}
+
+	if failed := report(os.Stdout, recordings, p); failed > 0 {
+		fmt.Printf("%d recordings failed.\n", failed)
+		os.Exit(1)
+	}
+}
+
+// report - print the summary of every recording, returning how many failed
+func report(out *os.File, recordings [][]string, p *progress) int {
+	failed := 0
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tDURATION\tDISTANCE\tGPS\tSTATUS")
+	for _, rec := range recordings {
+		s := p.done[rec[0]]
+		file := strings.Join(rec, "+")
+
+		status := "ok"
+		if s.Error != "" {
+			status = s.Error
+			failed++
+		} else if len(s.Warnings) > 0 {
+			status = fmt.Sprintf("%d warnings", len(s.Warnings))
+		}
+
+		fmt.Fprintf(w, "%s\t%.0fs\t%.2fkm\t%.0f%%\t%s\n", file, s.Duration, s.Distance/1000, 100*s.Coverage, status)
+	}
+	w.Flush()
+
+	return failed
+}
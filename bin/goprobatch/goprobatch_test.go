@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stilldavid/gopro-utils/gpmf"
+)
+
+func TestCurrent(t *testing.T) {
+	modified := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	o := options{formats: []string{formatJSON, formatGPX}}
+	s := summary{Size: 100, Modified: modified, Formats: o.formats}
+
+	tests := []struct {
+		name     string
+		size     int64
+		modified time.Time
+		o        options
+		want     bool
+	}{
+		{"unchanged", 100, modified, o, true},
+		{"fewer formats", 100, modified, options{formats: []string{formatGPX}}, true},
+		{"grown", 200, modified, o, false},
+		{"modified", 100, modified.Add(time.Second), o, false},
+		{"another device", 100, modified, options{formats: o.formats, device: "Karma"}, false},
+		{"lenient", 100, modified, options{formats: o.formats, lenient: true}, false},
+	}
+
+	for _, test := range tests {
+		if got := s.current(test.size, test.modified, test.o); got != test.want {
+			t.Errorf("%s: current = %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	more := options{formats: []string{formatJSON}}
+	if s := (summary{Size: 100, Modified: modified, Formats: []string{formatGPX}}); s.current(100, modified, more) {
+		t.Error("a format not written counted as done")
+	}
+	if s := (summary{Size: 100, Modified: modified, Formats: o.formats, Error: "EOF"}); s.current(100, modified, o) {
+		t.Error("a failed run counted as done")
+	}
+}
+
+func TestProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goprobatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, ".goprobatch")
+
+	modified := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	o := options{formats: []string{formatJSON}}
+
+	p, err := openProgress(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"GOPR0001.MP4", "GOPR0002.MP4"} {
+		if err := p.Add(summary{File: file, Size: 100, Modified: modified, Formats: o.formats}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Close()
+
+	// stopped while writing the next line
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"file":"GOPR0003.MP4","si`)
+	f.Close()
+
+	p, err = openProgress(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if !p.Done("GOPR0001.MP4", 100, modified, o) || !p.Done("GOPR0002.MP4", 100, modified, o) {
+		t.Errorf("lost the files done: %v", p.done)
+	}
+	if p.Done("GOPR0003.MP4", 0, time.Time{}, o) || len(p.done) != 2 {
+		t.Errorf("the line cut short was read: %v", p.done)
+	}
+	if p.Done("GOPR0001.MP4", 100, modified, options{formats: o.formats, lenient: true}) {
+		t.Error("done with other options")
+	}
+}
+
+// telemetryFile - three one second payloads of two GPS5 samples each,
+// heading north from the equator, the last without a fix
+func telemetryFile() []byte {
+	var file bytes.Buffer
+	enc := gpmf.NewEncoder(&file)
+	for i, fix := range []uint32{3, 3, 0} {
+		scal, _ := gpmf.NewScale(10000000, 10000000, 1000, 1000, 100)
+		gpsf, _ := gpmf.NewValues("GPSF", 'L', 1, fix)
+		gps5, _ := gpmf.NewValues("GPS5", 'l', 5,
+			int32(i*10000), 0, 0, 0, 0,
+			int32(i*10000+5000), 0, 0, 0, 0)
+		enc.Encode(gpmf.NewContainer("DEVC", gpmf.NewContainer("STRM",
+			gpmf.NewString("GPSU", "170301120000.000"), gpsf, scal, gps5,
+		)))
+	}
+	return file.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goprobatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "GOPR0001.bin")
+	if err := ioutil.WriteFile(name, telemetryFile(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := process([]string{name}, options{formats: []string{formatJSON, formatGPX}})
+	if s.Error != "" {
+		t.Fatal(s.Error)
+	}
+
+	if s.Duration != 3 {
+		t.Errorf("duration %vs, want 3s", s.Duration)
+	}
+	// 1.5 thousandths of a degree of latitude over the samples of the
+	// payloads with a fix, not only the first of each
+	if math.Abs(s.Distance-167) > 0.5 {
+		t.Errorf("distance %vm, want about 167m", s.Distance)
+	}
+	if math.Abs(s.Coverage-2.0/3) > 1e-9 {
+		t.Errorf("coverage %v, want 2/3", s.Coverage)
+	}
+
+	if len(s.Outputs) != 2 || s.Outputs[0] != "GOPR0001.bin.json" || s.Outputs[1] != "GOPR0001.bin.gpx" {
+		t.Fatalf("wrote %v", s.Outputs)
+	}
+	for _, out := range s.Outputs {
+		if _, err := os.Stat(filepath.Join(dir, out)); err != nil {
+			t.Error(err)
+		}
+	}
+}
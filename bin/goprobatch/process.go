@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/paulmach/go.geo"
+	"github.com/stilldavid/gopro-utils/bin/internal/cli"
+	"github.com/stilldavid/gopro-utils/gpmf"
+	"github.com/stilldavid/gopro-utils/telemetry"
+)
+
+// output formats, by extension of the file written
+const (
+	formatJSON = "json"
+	formatGPX  = "gpx"
+)
+
+// options - what to do with each file
+type options struct {
+	formats []string
+	device  string
+	lenient bool
+}
+
+// summary - what was made of one file, as kept in the progress file
+type summary struct {
+	File     string    `json:"file"`               // relative to the directory walked
+	Chapters []string  `json:"chapters,omitempty"` // read after it, as the same recording
+	Size     int64     `json:"size"`               // of all the files
+	Modified time.Time `json:"modified"`           // the latest of them
+
+	// the options it was processed with
+	Formats []string `json:"formats"`
+	Device  string   `json:"device,omitempty"`
+	Lenient bool     `json:"lenient,omitempty"`
+
+	Duration float64  `json:"duration"`     // seconds of telemetry
+	Distance float64  `json:"distance"`     // meters travelled
+	Coverage float64  `json:"gps_coverage"` // share of the duration with a 2D/3D fix
+	Outputs  []string `json:"outputs,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// current - whether s is a finished run over the files as they are now,
+// with the options given: the same device and leniency, and every format
+// asked for written
+func (s *summary) current(size int64, modified time.Time, o options) bool {
+	if s.Error != "" || s.Size != size || !s.Modified.Equal(modified) {
+		return false
+	}
+	if s.Device != o.device || s.Lenient != o.lenient {
+		return false
+	}
+
+	for _, format := range o.formats {
+		written := false
+		for _, f := range s.Formats {
+			written = written || f == format
+		}
+		if !written {
+			return false
+		}
+	}
+	return true
+}
+
+// process - decode the chapters of a recording at paths, usually just the
+// one, write the outputs next to the first and summarize it. Failures are
+// reported in the summary.
+func process(paths []string, o options) summary {
+	s := summary{Formats: o.formats, Device: o.device, Lenient: o.lenient}
+	if err := run(paths, o, &s); err != nil {
+		s.Error = err.Error()
+	}
+	return s
+}
+
+func run(paths []string, o options, s *summary) error {
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		s.Size += info.Size()
+		if info.ModTime().After(s.Modified) {
+			s.Modified = info.ModTime()
+		}
+	}
+
+	dec, done, err := cli.Open(paths, o.device, o.lenient)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	streams := map[string]telemetry.StreamInfo{}
+	tracker := telemetry.NewTracker()
+
+	// rows of each device, eg. the camera and a drone, and how long each had
+	// a fix and how far it went: the first is the one summarized
+	var devices cli.Devices
+	covered := map[uint32]time.Duration{}
+	travelled := map[uint32]*travel{}
+
+	var end time.Duration
+
+	for {
+		t, err := dec.Next()
+		if err == io.EOF {
+			break
+		} else if errors.Is(err, gpmf.ErrTruncated) {
+			s.Warnings = append(s.Warnings, err.Error())
+			if t == nil {
+				continue
+			}
+		} else if err != nil {
+			return err
+		}
+
+		if t.Start+t.Duration > end {
+			end = t.Start + t.Duration
+		}
+		for key, info := range t.Streams {
+			streams[key] = info
+		}
+
+		// no GPS time yet, nothing to report
+		if t.IsZero() {
+			continue
+		}
+
+		rows := tracker.Rows(t)
+		if len(rows) > 0 && fixOf(t, 0) >= 2 {
+			covered[t.Device.ID] += t.Duration
+		}
+
+		tr := travelled[t.Device.ID]
+		if tr == nil {
+			tr = &travel{}
+			travelled[t.Device.ID] = tr
+		}
+		for i, row := range rows {
+			tr.add(row, fixOf(t, i))
+		}
+
+		devices.Add(t.Device, rows)
+	}
+
+	for _, skip := range dec.Skipped() {
+		s.Warnings = append(s.Warnings, fmt.Sprintf("Skipped %d bytes at offset %d: %s", skip.Length, skip.Offset, skip.Err))
+	}
+
+	s.Duration = end.Seconds()
+	if first, ok := devices.First(); ok {
+		s.Distance = travelled[first.ID].meters
+		if end > 0 {
+			s.Coverage = float64(covered[first.ID]) / float64(end)
+		}
+	}
+
+	// named after the whole file name, so that GOPR0001.MP4 and a
+	// GOPR0001.bin beside it don't write over each other's outputs
+	base := paths[0]
+	for _, format := range o.formats {
+		var b []byte
+		var err error
+		switch format {
+		case formatJSON:
+			b, err = json.Marshal(devices.Data(streams))
+		case formatGPX:
+			b, err = devices.GPX()
+		}
+		if err != nil {
+			return err
+		}
+
+		name := base + "." + format
+		if err := ioutil.WriteFile(name, b, 0644); err != nil {
+			return err
+		}
+		s.Outputs = append(s.Outputs, filepath.Base(name))
+	}
+
+	return nil
+}
+
+// fixOf - the fix of the i-th GPS sample of a payload: its own with GPS9,
+// else the payload's GPSF, which holds for all its GPS5 samples
+func fixOf(t *telemetry.TELEM, i int) uint32 {
+	if len(t.Gps9) > 0 {
+		return uint32(t.Gps9[i].Fix)
+	}
+	return t.GpsFix.F
+}
+
+// travel - how far a device went between its samples with a 2D/3D fix
+type travel struct {
+	meters float64
+	prev   *geo.Point
+}
+
+func (tr *travel) add(row telemetry.TELEM_OUT, fix uint32) {
+	if fix < 2 {
+		return
+	}
+	p := geo.NewPoint(row.Longitude, row.Latitude)
+	if tr.prev != nil {
+		tr.meters += tr.prev.GeoDistanceFrom(p, true)
+	}
+	tr.prev = p
+}
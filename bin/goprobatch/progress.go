@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// progress - the summaries of files already processed, one JSON object per
+// line, appended to as each file finishes so an interrupted run can carry on
+// where it stopped
+type progress struct {
+	f    *os.File
+	done map[string]summary // by summary.File, the latest run of each
+}
+
+// openProgress - load the progress file at name, creating it if need be
+func openProgress(name string) (*progress, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &progress{f: f, done: map[string]summary{}}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		s := summary{}
+		// a line cut short when the last run was stopped is ignored
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			continue
+		}
+		p.done[s.File] = s
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Done - whether the recording starting with file, of the given total size
+// and latest modification, was already processed with the options o
+func (p *progress) Done(file string, size int64, modified time.Time, o options) bool {
+	s, ok := p.done[file]
+	return ok && s.current(size, modified, o)
+}
+
+// Add - record the summary of a file just processed
+func (p *progress) Add(s summary) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	p.done[s.File] = s
+	_, err = p.f.Write(append(b, '\n'))
+	return err
+}
+
+// Close - close the progress file
+func (p *progress) Close() error {
+	return p.f.Close()
+}
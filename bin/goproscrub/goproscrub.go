@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/stilldavid/gopro-utils/gpmf"
+	"github.com/stilldavid/gopro-utils/mp4"
+)
+
+func main() {
+	inName := flag.String("i", "", "Required: telemetry file (.bin) or GoPro video (.MP4) to read")
+	outName := flag.String("o", "", "Required: file of the same kind to write")
+	mode := flag.String("mode", modeZero, "What to do with GPS inside the zones: zero, remove or mask (move to a random point of the zone)")
+
+	var z zones
+	flag.Var(radiusFlag{&z}, "radius", "Zone of \"lat,lon,meters\"; repeatable")
+	flag.Var(polygonFlag{&z}, "polygon", "Zone of \"lat,lon lat,lon lat,lon ...\"; repeatable")
+	flag.Parse()
+
+	if *inName == "" || *outName == "" {
+		flag.Usage()
+		return
+	}
+	if *mode != modeZero && *mode != modeRemove && *mode != modeMask {
+		fmt.Printf("Unknown mode %s.\n", *mode)
+		os.Exit(1)
+	}
+	if len(z) == 0 {
+		fmt.Println("No zones given, scrubbing all GPS.")
+	}
+
+	inFile, err := os.Open(*inName)
+	if err != nil {
+		fmt.Printf("Cannot access input file %s.\n", *inName)
+		os.Exit(1)
+	}
+	defer inFile.Close()
+
+	// creating the output would empty the input before it is read
+	if in, err := inFile.Stat(); err == nil {
+		if out, err := os.Stat(*outName); err == nil && os.SameFile(in, out) {
+			fmt.Printf("Cannot write %s over itself, give another output file.\n", *inName)
+			os.Exit(1)
+		}
+	}
+
+	outFile, err := os.Create(*outName)
+	if err != nil {
+		fmt.Printf("Cannot make output file %s.\n", *outName)
+		os.Exit(1)
+	}
+
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			fmt.Printf("Cannot close output file %s: %s", file.Name(), err)
+			os.Exit(1)
+		}
+	}(outFile)
+
+	s := newScrubber(*mode, z)
+
+	if mp4.Sniff(inFile) {
+		err = scrubVideo(s, outFile, inFile)
+	} else {
+		err = scrubBin(s, outFile, inFile)
+	}
+	if err != nil {
+		fmt.Println("Error scrubbing", *inName, err)
+		os.Exit(1)
+	}
+
+	for _, line := range s.report() {
+		fmt.Println(line)
+	}
+}
+
+// scrubVideo - rewrite the video with every metadata sample scrubbed
+func scrubVideo(s *scrubber, dst io.Writer, src *os.File) error {
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	return mp4.Rewrite(dst, src, info.Size(), func(i int, payload []byte) ([]byte, error) {
+		nodes, err := gpmf.Parse(payload)
+		if err != nil {
+			return nil, fmt.Errorf("sample %d: %s", i, err)
+		}
+
+		var out bytes.Buffer
+		if err := scrubNodes(s, gpmf.NewEncoder(&out), nodes); err != nil {
+			return nil, fmt.Errorf("sample %d: %s", i, err)
+		}
+		return out.Bytes(), nil
+	})
+}
+
+// scrubBin - scrub an extracted telemetry file one element at a time
+func scrubBin(s *scrubber, dst io.Writer, src io.Reader) error {
+	enc := gpmf.NewEncoder(dst)
+	for {
+		n, err := gpmf.ReadNode(src)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := scrubNodes(s, enc, []*gpmf.Node{n}); err != nil {
+			return err
+		}
+	}
+}
+
+func scrubNodes(s *scrubber, enc *gpmf.Encoder, nodes []*gpmf.Node) error {
+	for _, n := range nodes {
+		if "DEVC" == n.Key() {
+			if err := s.devc(n); err != nil {
+				return err
+			}
+		}
+		if err := enc.Encode(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/stilldavid/gopro-utils/gpmf"
+	"github.com/stilldavid/gopro-utils/mp4"
+)
+
+// home, and a zone of 500m around it
+const homeLat, homeLon = 47.6062, -122.3321
+
+var home = radius{homeLat, homeLon, 500}
+
+func TestZones(t *testing.T) {
+	square := polygon{lats: []float64{47.60, 47.60, 47.61, 47.61}, lons: []float64{-122.34, -122.33, -122.33, -122.34}}
+
+	tests := []struct {
+		name     string
+		z        zone
+		lat, lon float64
+		want     bool
+	}{
+		{"radius center", home, homeLat, homeLon, true},
+		{"radius inside", home, homeLat + 0.003, homeLon, true},   // ~334m north
+		{"radius outside", home, homeLat + 0.005, homeLon, false}, // ~556m north
+		{"radius just inside the edge", home, homeLat + 499.9/distance(0, 0, 1, 0), homeLon, true},
+		{"radius just outside the edge", home, homeLat + 500.1/distance(0, 0, 1, 0), homeLon, false},
+		{"radius across the meridian", radius{0, 179.9999, 50}, 0, -179.9999, true},
+		{"polygon inside", square, 47.605, -122.335, true},
+		{"polygon outside", square, 47.615, -122.335, false},
+		{"polygon outside beside", square, 47.605, -122.32, false},
+		{"polygon west edge", square, 47.605, -122.34, true},
+		{"polygon east edge", square, 47.605, -122.33, true},
+		{"polygon north edge", square, 47.61, -122.335, true},
+		{"polygon vertex", square, 47.61, -122.33, true},
+	}
+
+	for _, test := range tests {
+		if got := test.z.contains(test.lat, test.lon); got != test.want {
+			t.Errorf("%s: contains(%v, %v) = %v, want %v", test.name, test.lat, test.lon, got, test.want)
+		}
+	}
+}
+
+// degrees - a GPS5 coordinate as the camera stores it, scaled by 1e7
+func degrees(v float64) int32 {
+	return int32(v * 1e7)
+}
+
+// gpsPayload - a DEVC with a GPSU, GPSP and GPS5 of two samples near home
+// and one 1km away, the stream having delivered tsmp samples by its end
+func gpsPayload(tsmp uint32) *gpmf.Node {
+	scal, _ := gpmf.NewScale(10000000, 10000000, 1000, 1000, 100)
+	total, _ := gpmf.NewValues("TSMP", 'L', 1, tsmp)
+	gpsp, _ := gpmf.NewValues("GPSP", 'S', 1, uint16(250))
+	gps5, _ := gpmf.NewValues("GPS5", 'l', 5,
+		degrees(homeLat), degrees(homeLon), 10000, 0, 0,
+		degrees(homeLat+0.001), degrees(homeLon), 10000, 0, 0,
+		degrees(homeLat+0.01), degrees(homeLon), 10000, 0, 0,
+	)
+	return gpmf.NewContainer("DEVC", gpmf.NewContainer("STRM",
+		total, gpmf.NewString("GPSU", "170301120000.000"), gpsp, scal, gps5,
+	))
+}
+
+func TestScrubModes(t *testing.T) {
+	tests := []struct {
+		mode                    string
+		gps5, gpsu, gpsp, count int    // altered, and GPS5 samples left
+		tsmp                    uint32 // by the end of the second payload
+	}{
+		{modeZero, 4, 2, 2, 3, 6},
+		{modeRemove, 4, 2, 2, 1, 2},
+		{modeMask, 4, 0, 0, 3, 6},
+	}
+
+	for _, test := range tests {
+		s := newScrubber(test.mode, zones{home})
+		var devc *gpmf.Node
+		for _, tsmp := range []uint32{3, 6} {
+			devc = gpsPayload(tsmp)
+			if err := s.devc(devc); err != nil {
+				t.Fatalf("%s: %v", test.mode, err)
+			}
+		}
+
+		if s.altered["GPS5"] != test.gps5 || s.altered["GPSU"] != test.gpsu || s.altered["GPSP"] != test.gpsp {
+			t.Errorf("%s: altered %v", test.mode, s.altered)
+		}
+		if s.total["GPS5"] != 6 || s.total["GPSU"] != 2 || s.total["GPSP"] != 2 {
+			t.Errorf("%s: saw %v", test.mode, s.total)
+		}
+
+		strm := devc.Find("STRM")
+		if tsmp := binary.BigEndian.Uint32(strm.Find("TSMP").Data); tsmp != test.tsmp {
+			t.Errorf("%s: TSMP %d, want %d", test.mode, tsmp, test.tsmp)
+		}
+		gps5 := strm.Find("GPS5")
+		if int(gps5.Count) != test.count {
+			t.Errorf("%s: %d GPS5 samples left, want %d", test.mode, gps5.Count, test.count)
+		}
+
+		// the sample outside the zone is never touched
+		last := gps5.Samples()[len(gps5.Samples())-1]
+		if int32(binary.BigEndian.Uint32(last)) != degrees(homeLat+0.01) {
+			t.Errorf("%s: the sample outside the zone was changed", test.mode)
+		}
+
+		switch test.mode {
+		case modeZero:
+			if !bytes.Equal(gps5.Samples()[0], make([]byte, 20)) {
+				t.Errorf("zero: first sample left as %v", gps5.Samples()[0])
+			}
+			if string(strm.Find("GPSU").Data) != zeroGPSU {
+				t.Errorf("zero: GPSU left as %q", strm.Find("GPSU").Data)
+			}
+		case modeRemove:
+			if strm.Find("GPSU") != nil || strm.Find("GPSP") != nil {
+				t.Error("remove: GPSU or GPSP left in")
+			}
+		case modeMask:
+			// both moved to the same point of the zone, without altitude
+			first, second := gps5.Samples()[0], gps5.Samples()[1]
+			if !bytes.Equal(first, second) || !bytes.Equal(first[8:12], make([]byte, 4)) {
+				t.Errorf("mask: samples moved to %v and %v", first, second)
+			}
+			lat := float64(int32(binary.BigEndian.Uint32(first[0:4]))) / 1e7
+			lon := float64(int32(binary.BigEndian.Uint32(first[4:8]))) / 1e7
+			if !home.contains(lat, lon) {
+				t.Errorf("mask: samples moved out of the zone to %v, %v", lat, lon)
+			}
+		}
+	}
+}
+
+func TestMaskHidesCenter(t *testing.T) {
+	square := polygon{lats: []float64{47.60, 47.60, 47.61, 47.61}, lons: []float64{-122.34, -122.33, -122.33, -122.34}}
+
+	tests := []struct {
+		name     string
+		z        zone
+		lat, lon float64 // the middle of the zone
+	}{
+		{"radius", home, homeLat, homeLon},
+		{"polygon", square, 47.605, -122.335},
+	}
+
+	for _, test := range tests {
+		// the first two samples are inside both zones
+		devc := gpsPayload(3)
+		if err := newScrubber(modeMask, zones{test.z}).devc(devc); err != nil {
+			t.Fatal(err)
+		}
+
+		// the third, outside, keeps its position, which may share a coordinate
+		for _, sample := range devc.Find("STRM").Find("GPS5").Samples()[:2] {
+			if bytes.Contains(sample[0:8], u32s(uint32(degrees(test.lat)))) || bytes.Contains(sample[0:8], u32s(uint32(degrees(test.lon)))) {
+				t.Errorf("%s: the middle of the zone is in the masked sample %v", test.name, sample)
+			}
+		}
+		if lat, lon := test.z.random(rand.New(rand.NewSource(1))); !test.z.contains(lat, lon) {
+			t.Errorf("%s: random point %v, %v outside the zone", test.name, lat, lon)
+		}
+	}
+}
+
+// u32s - big endian 32-bit words
+func u32s(vals ...uint32) []byte {
+	b := make([]byte, 4*len(vals))
+	for i, v := range vals {
+		binary.BigEndian.PutUint32(b[4*i:], v)
+	}
+	return b
+}
+
+// box - an MP4 box of the given parts
+func box(typ string, parts ...[]byte) []byte {
+	body := bytes.Join(parts, nil)
+	return append(append(u32s(uint32(8+len(body))), typ...), body...)
+}
+
+// testVideo - an MP4 whose one-sample gpmd track holds payload, next to
+// some stand-in video data in the same mdat
+func testVideo(payload []byte) []byte {
+	ftyp := box("ftyp", []byte("mp41"), u32s(0))
+	video := []byte("video frame data")
+	mdat := box("mdat", video, payload)
+	offset := uint32(len(ftyp) + 8 + len(video))
+
+	hdlr := box("hdlr", u32s(0, 0), []byte("meta"), u32s(0, 0, 0), []byte("\tGoPro MET\x00"))
+	mdhd := box("mdhd", u32s(0, 0, 0, 1000, 1000), u32s(0))
+	stsd := box("stsd", u32s(0, 1), box("gpmd", u32s(0, 1)))
+	stts := box("stts", u32s(0, 1, 1, 1000))
+	stsc := box("stsc", u32s(0, 1, 1, 1, 1))
+	stsz := box("stsz", u32s(0, 0, 1, uint32(len(payload))))
+	stco := box("stco", u32s(0, 1, offset))
+
+	stbl := box("stbl", stsd, stts, stsc, stsz, stco)
+	moov := box("moov", box("trak", box("mdia", mdhd, hdlr, box("minf", stbl))))
+
+	return bytes.Join([][]byte{ftyp, mdat, moov}, nil)
+}
+
+func TestScrubVideo(t *testing.T) {
+	payload, _ := gpsPayload(3).MarshalBinary()
+
+	f, err := ioutil.TempFile("", "goproscrub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := f.Write(testVideo(payload)); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := scrubVideo(newScrubber(modeZero, zones{home}), &out, f); err != nil {
+		t.Fatal(err)
+	}
+
+	// no trace of the positions inside the zone anywhere in the file
+	for _, lat := range []float64{homeLat, homeLat + 0.001} {
+		if bytes.Contains(out.Bytes(), u32s(uint32(degrees(lat)))) {
+			t.Errorf("latitude %v is still in the scrubbed video", lat)
+		}
+	}
+	if !bytes.Contains(out.Bytes(), []byte("video frame data")) {
+		t.Error("the video data was lost")
+	}
+
+	track, err := mp4.Open(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scrubbed, err := track.Payload(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nodes, err := gpmf.Parse(scrubbed); err != nil || len(nodes) != 1 {
+		t.Errorf("scrubbed payload decodes as %v, %v", nodes, err)
+	}
+}
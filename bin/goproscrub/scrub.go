@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/stilldavid/gopro-utils/gpmf"
+	"github.com/stilldavid/gopro-utils/telemetry"
+)
+
+// what happens to GPS inside the zones
+const (
+	modeZero   = "zero"   // positions, speeds and times zeroed
+	modeRemove = "remove" // samples and their GPSU/GPSP dropped
+	modeMask   = "mask"   // positions moved to a random point of the zone
+)
+
+// placeholder time for zeroed GPSU, still a valid timestamp
+const zeroGPSU = "000101000000.000"
+
+// scrubber - alters GPS in DEVC payloads and counts what it changed
+type scrubber struct {
+	mode  string
+	zones zones
+
+	total   map[string]int // GPS samples seen per stream
+	altered map[string]int // ... and changed
+
+	// where masked samples of each zone go, picked once per run so that it
+	// says nothing about what the zone hides, like its middle would
+	rand  *rand.Rand
+	masks map[int][2]float64
+
+	removed map[string]uint32 // samples dropped so far, by device and stream
+}
+
+func newScrubber(mode string, z zones) *scrubber {
+	return &scrubber{
+		mode:    mode,
+		zones:   z,
+		total:   map[string]int{},
+		altered: map[string]int{},
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		masks:   map[int][2]float64{},
+		removed: map[string]uint32{},
+	}
+}
+
+// zoneOf - the index of the zone a position falls in, -1 when there are no
+// zones as everything is hidden then
+func (s *scrubber) zoneOf(lat, lon float64) (int, bool) {
+	if len(s.zones) == 0 {
+		return -1, true
+	}
+	for i, z := range s.zones {
+		if z.contains(lat, lon) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// mask - where the samples inside zone i are moved to: null island when all
+// GPS is hidden
+func (s *scrubber) mask(i int) (float64, float64) {
+	if i < 0 {
+		return 0, 0
+	}
+	m, ok := s.masks[i]
+	if !ok {
+		m[0], m[1] = s.zones[i].random(s.rand)
+		s.masks[i] = m
+	}
+	return m[0], m[1]
+}
+
+// devc - scrub one device payload in place
+func (s *scrubber) devc(devc *gpmf.Node) error {
+	touched := false
+
+	device := ""
+	if dvid := devc.Find("DVID"); dvid != nil {
+		device = string(dvid.Data)
+	}
+
+	for _, strm := range devc.Children {
+		if "STRM" != strm.Key() {
+			continue
+		}
+
+		scale := telemetry.SCAL{}
+		stream := ""
+		for _, n := range strm.Children {
+			switch n.Key() {
+			case "SCAL":
+				if err := scale.Parse(n.Data, int64(n.Size)); err != nil {
+					return err
+				}
+			case "GPS5", "GPS9":
+				count := n.Count
+				hit, err := s.positions(n, &scale)
+				if err != nil {
+					return err
+				}
+				touched = touched || hit
+
+				stream = device + n.Key()
+				s.removed[stream] += uint32(count - n.Count)
+			case "GPSU", "GPSP":
+				s.total[n.Key()]++
+			}
+		}
+
+		if s.mode == modeRemove {
+			strm.Filter(func(n *gpmf.Node) bool {
+				return len(n.Data) > 0 || ("GPS5" != n.Key() && "GPS9" != n.Key())
+			})
+
+			// the running count goes on from the samples left
+			if tsmp := strm.Find("TSMP"); tsmp != nil && len(tsmp.Data) == 4 && s.removed[stream] > 0 {
+				total := binary.BigEndian.Uint32(tsmp.Data)
+				binary.BigEndian.PutUint32(tsmp.Data, total-s.removed[stream])
+			}
+		}
+	}
+
+	// the payload's GPS time and precision go with its positions
+	if !touched || s.mode == modeMask {
+		return nil
+	}
+
+	for _, strm := range devc.Children {
+		if "STRM" != strm.Key() {
+			continue
+		}
+
+		for _, n := range strm.Children {
+			if "GPSU" != n.Key() && "GPSP" != n.Key() {
+				continue
+			}
+
+			s.altered[n.Key()]++
+
+			if "GPSU" == n.Key() && s.mode == modeZero {
+				copy(n.Data, zeroGPSU)
+			} else if "GPSP" == n.Key() && s.mode == modeZero {
+				// 9999 is how the camera reports no precision
+				for i := 0; i+2 <= len(n.Data); i += 2 {
+					binary.BigEndian.PutUint16(n.Data[i:], 9999)
+				}
+			}
+		}
+
+		if s.mode == modeRemove {
+			strm.Filter(func(n *gpmf.Node) bool {
+				return "GPSU" != n.Key() && "GPSP" != n.Key()
+			})
+		}
+	}
+
+	return nil
+}
+
+// positions - scrub the samples of a GPS5 or GPS9 element, reporting whether
+// any fell in a zone
+func (s *scrubber) positions(n *gpmf.Node, scale *telemetry.SCAL) (bool, error) {
+	var kept []byte
+	hit := false
+
+	for _, value := range n.Samples() {
+		var lat, lon float64
+		if "GPS5" == n.Key() {
+			g := telemetry.GPS5{}
+			if err := g.Parse(value, scale); err != nil {
+				return false, err
+			}
+			lat, lon = g.Latitude, g.Longitude
+		} else {
+			g := telemetry.GPS9{}
+			if err := g.Parse(value, scale); err != nil {
+				return false, err
+			}
+			lat, lon = g.Latitude, g.Longitude
+		}
+
+		s.total[n.Key()]++
+
+		z, inside := s.zoneOf(lat, lon)
+		if !inside {
+			kept = append(kept, value...)
+			continue
+		}
+
+		hit = true
+		s.altered[n.Key()]++
+
+		switch s.mode {
+		case modeRemove:
+			continue
+		case modeZero:
+			// position, altitude, speeds and, for GPS9, its own time and fix
+			for i := range value {
+				value[i] = 0
+			}
+		case modeMask:
+			lat, lon = s.mask(z)
+			putScaled(value[0:4], lat, scale, 0)
+			putScaled(value[4:8], lon, scale, 1)
+			// altitude
+			for i := 8; i < 12; i++ {
+				value[i] = 0
+			}
+		}
+		kept = append(kept, value...)
+	}
+
+	if s.mode == modeRemove {
+		n.Data = kept
+		n.Count = uint16(len(kept) / int(n.Size))
+	}
+
+	return hit, nil
+}
+
+// putScaled - store a degree value as the raw int32 the stream's SCAL expects
+func putScaled(b []byte, v float64, scale *telemetry.SCAL, axis int) {
+	divisor := 1.0
+	if len(scale.Values) == 1 {
+		divisor = float64(scale.Values[0])
+	} else if axis < len(scale.Values) {
+		divisor = float64(scale.Values[axis])
+	}
+
+	binary.BigEndian.PutUint32(b, uint32(int32(math.Round(v*divisor))))
+}
+
+// report - one line per stream
+func (s *scrubber) report() []string {
+	var lines []string
+	for _, key := range []string{"GPS5", "GPS9", "GPSU", "GPSP"} {
+		if s.total[key] == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s: %d of %d samples altered (%s)", key, s.altered[key], s.total[key], s.mode))
+	}
+	return lines
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// area that must not be published
+type zone interface {
+	contains(lat, lon float64) bool
+	random(r *rand.Rand) (lat, lon float64) // anywhere inside
+}
+
+// everything within meters of a point
+type radius struct {
+	lat, lon, meters float64
+}
+
+// everything inside a polygon of lat,lon vertices
+type polygon struct {
+	lats, lons []float64
+}
+
+// mean earth radius in meters
+const earthRadius = 6371008.8
+
+func (r radius) contains(lat, lon float64) bool {
+	return distance(r.lat, r.lon, lat, lon) <= r.meters
+}
+
+// random - a point up to 90% of the way to the edge, keeping clear of it
+// where the flat earth approximation is off
+func (r radius) random(rnd *rand.Rand) (float64, float64) {
+	d := 0.9 * r.meters * math.Sqrt(rnd.Float64())
+	bearing := 2 * math.Pi * rnd.Float64()
+
+	perDegree := distance(0, 0, 1, 0)
+	lat := r.lat + d*math.Cos(bearing)/perDegree
+	lon := r.lon + d*math.Sin(bearing)/(perDegree*math.Cos(r.lat*math.Pi/180))
+	return lat, lon
+}
+
+// ray casting; fine for the small areas people draw around their homes.
+// Points on the boundary are inside, so they are hidden too.
+func (p polygon) contains(lat, lon float64) bool {
+	inside := false
+	for i, j := 0, len(p.lats)-1; i < len(p.lats); j, i = i, i+1 {
+		if onSegment(lat, lon, p.lats[j], p.lons[j], p.lats[i], p.lons[i]) {
+			return true
+		}
+		if (p.lats[i] > lat) != (p.lats[j] > lat) &&
+			lon < (p.lons[j]-p.lons[i])*(lat-p.lats[i])/(p.lats[j]-p.lats[i])+p.lons[i] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// onSegment - whether a point lies on the edge between two vertices
+func onSegment(lat, lon, lat1, lon1, lat2, lon2 float64) bool {
+	cross := (lat-lat1)*(lon2-lon1) - (lon-lon1)*(lat2-lat1)
+	if math.Abs(cross) > 1e-12 {
+		return false
+	}
+	return math.Min(lat1, lat2) <= lat && lat <= math.Max(lat1, lat2) &&
+		math.Min(lon1, lon2) <= lon && lon <= math.Max(lon1, lon2)
+}
+
+// random - a point of the bounding box that falls inside, or a vertex for a
+// polygon too thin to hit
+func (p polygon) random(rnd *rand.Rand) (float64, float64) {
+	minLat, maxLat, minLon, maxLon := p.lats[0], p.lats[0], p.lons[0], p.lons[0]
+	for i := range p.lats {
+		minLat, maxLat = math.Min(minLat, p.lats[i]), math.Max(maxLat, p.lats[i])
+		minLon, maxLon = math.Min(minLon, p.lons[i]), math.Max(maxLon, p.lons[i])
+	}
+
+	for try := 0; try < 1000; try++ {
+		lat := minLat + (maxLat-minLat)*rnd.Float64()
+		lon := minLon + (maxLon-minLon)*rnd.Float64()
+		if p.contains(lat, lon) {
+			return lat, lon
+		}
+	}
+	return p.lats[0], p.lons[0]
+}
+
+// distance - haversine distance in meters
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+// zones - repeatable flag collecting zones
+type zones []zone
+
+func (z *zones) String() string {
+	if z == nil {
+		return ""
+	}
+	return strconv.Itoa(len(*z)) + " zones"
+}
+
+// radiusFlag - "lat,lon,meters"
+type radiusFlag struct{ *zones }
+
+func (f radiusFlag) Set(s string) error {
+	v, err := floats(s)
+	if err != nil || len(v) != 3 || v[2] <= 0 {
+		return errors.New("expected lat,lon,meters")
+	}
+	*f.zones = append(*f.zones, radius{v[0], v[1], v[2]})
+	return nil
+}
+
+// polygonFlag - "lat,lon lat,lon lat,lon ..."
+type polygonFlag struct{ *zones }
+
+func (f polygonFlag) Set(s string) error {
+	p := polygon{}
+	for _, vertex := range strings.Fields(s) {
+		v, err := floats(vertex)
+		if err != nil || len(v) != 2 {
+			return errors.New("expected space separated lat,lon vertices")
+		}
+		p.lats = append(p.lats, v[0])
+		p.lons = append(p.lons, v[1])
+	}
+	if len(p.lats) < 3 {
+		return errors.New("a polygon needs at least 3 vertices")
+	}
+	*f.zones = append(*f.zones, p)
+	return nil
+}
+
+func floats(s string) ([]float64, error) {
+	var v []float64
+	for _, part := range strings.Split(s, ",") {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		v = append(v, f)
+	}
+	return v, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/stilldavid/gopro-utils/gpmf"
+	"github.com/stilldavid/gopro-utils/mp4"
+)
+
+func main() {
+	inName := flag.String("i", "", "Required: GoPro video (.MP4) to read")
+	outName := flag.String("o", "", "Required: video file to write")
+	binName := flag.String("bin", "", "Replacement telemetry (.bin) with one DEVC per metadata sample")
+	strip := flag.String("strip", "", "Comma separated streams to remove, eg. GPS5,GPS9,GPSU,GPSP,GPSF")
+	flag.Parse()
+
+	if *inName == "" || *outName == "" || (*binName == "" && *strip == "") {
+		flag.Usage()
+		return
+	}
+
+	videoFile, err := os.Open(*inName)
+	if err != nil {
+		fmt.Printf("Cannot access video file %s.\n", *inName)
+		os.Exit(1)
+	}
+	defer videoFile.Close()
+
+	info, err := videoFile.Stat()
+	if err != nil {
+		fmt.Printf("Cannot access video file %s.\n", *inName)
+		os.Exit(1)
+	}
+
+	// creating the output would empty the video before it is read
+	if out, err := os.Stat(*outName); err == nil && os.SameFile(info, out) {
+		fmt.Printf("Cannot write %s over itself, give another output file.\n", *inName)
+		os.Exit(1)
+	}
+
+	gpmd, err := mp4.Open(videoFile, info.Size())
+	if err != nil {
+		fmt.Printf("Cannot read telemetry from %s: %s\n", *inName, err)
+		os.Exit(1)
+	}
+
+	// replacement payloads, in sample order
+	var replacements []*gpmf.Node
+	if *binName != "" {
+		replacements, err = readBin(*binName)
+		if err != nil {
+			fmt.Printf("Cannot read replacement telemetry %s: %s\n", *binName, err)
+			os.Exit(1)
+		}
+		if len(replacements) != len(gpmd.Samples) {
+			fmt.Printf("%s has %d payloads but %s has %d metadata samples\n", *binName, len(replacements), *inName, len(gpmd.Samples))
+			os.Exit(1)
+		}
+	}
+
+	drop := map[string]bool{}
+	for _, key := range strings.Split(*strip, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			drop[key] = true
+		}
+	}
+
+	stripped := 0
+	edit := func(i int, payload []byte) ([]byte, error) {
+		var nodes []*gpmf.Node
+		if replacements != nil {
+			nodes = replacements[i : i+1]
+		} else {
+			parsed, err := gpmf.Parse(payload)
+			if err != nil {
+				return nil, fmt.Errorf("sample %d: %s", i, err)
+			}
+			nodes = parsed
+		}
+
+		var out bytes.Buffer
+		enc := gpmf.NewEncoder(&out)
+		for _, devc := range nodes {
+			stripped += devc.Filter(func(strm *gpmf.Node) bool {
+				for _, c := range strm.Children {
+					if drop[c.Key()] {
+						return false
+					}
+				}
+				return true
+			})
+
+			if err := enc.Encode(devc); err != nil {
+				return nil, fmt.Errorf("sample %d: %s", i, err)
+			}
+		}
+
+		return out.Bytes(), nil
+	}
+
+	outFile, err := os.Create(*outName)
+	if err != nil {
+		fmt.Printf("Cannot make output file %s.\n", *outName)
+		os.Exit(1)
+	}
+
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			fmt.Printf("Cannot close video file %s: %s", file.Name(), err)
+			os.Exit(1)
+		}
+	}(outFile)
+
+	if err := mp4.Rewrite(outFile, videoFile, info.Size(), edit); err != nil {
+		fmt.Println("Error writing video file", err)
+		os.Exit(1)
+	}
+
+	if len(drop) > 0 {
+		fmt.Printf("Removed %d streams from %d payloads\n", stripped, len(gpmd.Samples))
+	}
+}
+
+// readBin - every top level element of an extracted telemetry file
+func readBin(name string) ([]*gpmf.Node, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var nodes []*gpmf.Node
+	for {
+		n, err := gpmf.ReadNode(f)
+		if err == io.EOF {
+			return nodes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+}
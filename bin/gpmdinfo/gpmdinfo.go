@@ -1,16 +1,21 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 
-	"github.com/stilldavid/gopro-utils/telemetry"
+	"github.com/stilldavid/gopro-utils/bin/internal/cli"
+	"github.com/stilldavid/gopro-utils/gpmf"
 )
 
 func main() {
-	inName := flag.String("i", "", "Required: telemetry file to read")
+	inName := flag.String("i", "", "Required: telemetry file (.bin) or GoPro video (.MP4) to read")
+	device := flag.String("device", "", "Only read telemetry of the device with this ID or name, eg. 1 or Camera")
+	lenient := flag.Bool("lenient", false, "Skip corrupt data instead of stopping at it")
+	chapters := flag.Bool("chapters", false, "Also read the other files of a recording the camera split up, eg. GH020001.MP4 with GH010001.MP4, as one")
 	flag.Parse()
 
 	if *inName == "" {
@@ -18,41 +23,41 @@ func main() {
 		return
 	}
 
-	telemFile, err := os.Open(*inName)
+	names, err := cli.Inputs(*inName, *chapters)
 	if err != nil {
-		fmt.Printf("Cannot access telemetry file %s.\n", *inName)
+		fmt.Println("Cannot look for chapters:", err)
 		os.Exit(1)
 	}
 
-	defer func(file *os.File) {
-		err := file.Close()
-		if err != nil {
-			fmt.Printf("Cannot close file %s: %s", file.Name(), err)
-			os.Exit(1)
-		}
-	}(telemFile)
-
-	// currently processing sentence
-	t := &telemetry.TELEM{}
+	// payloads in order, carrying on from one chapter to the next
+	dec, done, err := cli.Open(names, *device, *lenient)
+	if err != nil {
+		fmt.Println("Cannot read telemetry:", err)
+		os.Exit(1)
+	}
+	defer done()
 
 	for {
-		t, err = telemetry.Read(telemFile)
-		if err != nil {
-			if err == io.EOF {
-				break
+		t, err := dec.Next()
+		if err == io.EOF {
+			break
+		} else if errors.Is(err, gpmf.ErrTruncated) {
+			// cut short, eg. by a dead battery: keep what was recorded
+			fmt.Println("Warning:", err)
+			if t == nil {
+				continue
 			}
-			fmt.Println(err)
+		} else if err != nil {
+			fmt.Println("Error reading telemetry file", err)
 			os.Exit(1)
 		}
 
-		if t == nil {
-			break
-		}
-
 		// this is pretty useless and info overload: change it to pick a field you want
 		// or mangle it to your wishes into JSON/CSV/format of choice
 		fmt.Println(t)
+	}
 
-		t = &telemetry.TELEM{}
+	for _, skip := range dec.Skipped() {
+		fmt.Printf("Skipped %d bytes at offset %d: %s\n", skip.Length, skip.Offset, skip.Err)
 	}
 }
@@ -0,0 +1,69 @@
+// Package cli holds what the command line tools share, so that they read
+// telemetry and write their outputs the same way.
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/stilldavid/gopro-utils/mp4"
+	"github.com/stilldavid/gopro-utils/telemetry"
+)
+
+// Inputs - the file at name, or with chapters every file of its recording
+// in order (see mp4.FindChapters)
+func Inputs(name string, chapters bool) ([]string, error) {
+	if !chapters {
+		return []string{name}, nil
+	}
+
+	names, err := mp4.FindChapters(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return names, nil
+}
+
+// Open - a decoder of the files at names, each a GoPro video or an extracted
+// .bin, one chapter after the other. Its payloads are timed by the metadata
+// track when there is one. Only the device given by ID or name is read,
+// unless device is empty, and lenient skips corrupt data. The files stay
+// open until done is called.
+func Open(names []string, device string, lenient bool) (dec *telemetry.Decoder, done func(), err error) {
+	var files []*os.File
+	done = func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			done()
+			return nil, nil, err
+		}
+		files = append(files, f)
+
+		r, track, err := mp4.Telemetry(f)
+		if err != nil {
+			done()
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		if dec == nil {
+			dec = telemetry.NewDecoder(r, track)
+		} else {
+			dec.Append(r, track)
+		}
+	}
+
+	if lenient {
+		dec.Lenient()
+	}
+	if device != "" {
+		dec.Select(device)
+	}
+
+	return dec, done, nil
+}
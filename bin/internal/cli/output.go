@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"time"
+
+	"github.com/stilldavid/gopro-utils/telemetry"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// Data - the gopro2json output: the rows of the first device with GPS, and
+// those of any other by name
+type Data struct {
+	Data    []telemetry.TELEM_OUT            `json:"data"`
+	Devices map[string][]telemetry.TELEM_OUT `json:"devices,omitempty"`
+	Streams map[string]telemetry.StreamInfo  `json:"streams,omitempty"`
+}
+
+// Devices - the GPS rows of each device of a recording, eg. the camera and
+// a drone, in the order the devices turn up
+type Devices struct {
+	order []telemetry.DVID
+	rows  map[uint32][]telemetry.TELEM_OUT
+}
+
+// Add - the rows of the next payload of dev
+func (d *Devices) Add(dev telemetry.DVID, rows []telemetry.TELEM_OUT) {
+	if d.rows == nil {
+		d.rows = map[uint32][]telemetry.TELEM_OUT{}
+	}
+	if _, ok := d.rows[dev.ID]; !ok {
+		d.order = append(d.order, dev)
+	}
+	d.rows[dev.ID] = append(d.rows[dev.ID], rows...)
+}
+
+// First - the first device with rows, which the outputs are about
+func (d *Devices) First() (telemetry.DVID, bool) {
+	if len(d.order) == 0 {
+		return telemetry.DVID{}, false
+	}
+	return d.order[0], true
+}
+
+// Data - the gopro2json output of the rows, with the streams given
+func (d *Devices) Data(streams map[string]telemetry.StreamInfo) Data {
+	data := Data{Streams: streams}
+	for i, dev := range d.order {
+		if i == 0 {
+			data.Data = d.rows[dev.ID]
+			continue
+		}
+		if data.Devices == nil {
+			data.Devices = map[string][]telemetry.TELEM_OUT{}
+		}
+		data.Devices[dev.String()] = d.rows[dev.ID]
+	}
+	return data
+}
+
+// GPX - the gopro2gpx output of the rows, a track for each device
+func (d *Devices) GPX() ([]byte, error) {
+	gpxData := new(gpx.GPX)
+
+	for _, dev := range d.order {
+		track := &gpx.GPXTrack{Name: dev.String()}
+		segment := new(gpx.GPXTrackSegment)
+
+		for _, row := range d.rows[dev.ID] {
+			segment.AppendPoint(
+				&gpx.GPXPoint{
+					Point: gpx.Point{
+						Latitude:  row.Latitude,
+						Longitude: row.Longitude,
+						Elevation: *gpx.NewNullableFloat64(row.Altitude),
+					},
+					Timestamp: time.Unix(row.TS/1000/1000, row.TS%(1000*1000)*1000),
+				},
+			)
+		}
+
+		track.AppendSegment(segment)
+		gpxData.AppendTrack(track)
+	}
+
+	return gpxData.ToXml(gpx.ToXmlParams{Version: "1.1", Indent: true})
+}
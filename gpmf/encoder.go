@@ -0,0 +1,294 @@
+package gpmf
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Encoder - writes GPMF elements to a stream
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder - encoder writing to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode - write n, its payload padded to 32 bits, and everything nested in it
+func (e *Encoder) Encode(n *Node) error {
+	b, err := n.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	_, err = e.w.Write(b)
+	return err
+}
+
+// MarshalBinary - n as GPMF bytes. Containers are rebuilt from Children, so
+// edits to the tree are picked up and their headers are recomputed.
+func (n *Node) MarshalBinary() ([]byte, error) {
+	if n.IsNested() {
+		var body []byte
+		for _, c := range n.Children {
+			b, err := c.MarshalBinary()
+			if err != nil {
+				return nil, err
+			}
+			body = append(body, b...)
+		}
+
+		// children are padded, so a 4 byte "structure" reaches further
+		// than the 16-bit count of single bytes
+		n.Size, n.Count = 1, uint16(len(body))
+		if len(body) > math.MaxUint16 {
+			if len(body)/4 > math.MaxUint16 {
+				return nil, errors.New("KLV: Container too large")
+			}
+			n.Size, n.Count = 4, uint16(len(body)/4)
+		}
+		n.Data = body
+	}
+
+	if len(n.Data) != n.Length() {
+		return nil, errors.New("KLV: Payload length does not match header")
+	}
+
+	header := make([]byte, 8, 8+padded(len(n.Data)))
+	copy(header[0:4], n.FourCC)
+	header[4] = n.Format
+	header[5] = n.Size
+	binary.BigEndian.PutUint16(header[6:8], n.Count)
+
+	// same rules as reading
+	if err := (&KLV{}).Parse(header); err != nil {
+		return nil, err
+	}
+
+	b := append(header, n.Data...)
+	return append(b, make([]byte, padded(len(n.Data))-len(n.Data))...), nil
+}
+
+// NewContainer - nested element (eg. DEVC, STRM) holding children
+func NewContainer(key string, children ...*Node) *Node {
+	return &Node{
+		KLV:      KLV{FourCC: []byte(key), Format: 0x00},
+		Children: children,
+	}
+}
+
+// NewString - 'c' element holding a single string (eg. STNM, DVNM, TYPE)
+func NewString(key string, s string) *Node {
+	n := &Node{
+		KLV:  KLV{FourCC: []byte(key), Format: 'c', Size: 1, Count: uint16(len(s))},
+		Data: []byte(s),
+	}
+
+	if len(s) > 0 && len(s) <= math.MaxUint8 {
+		n.Size, n.Count = uint8(len(s)), 1
+	}
+
+	return n
+}
+
+// NewStrings - 'c' element holding one fixed width, zero padded string per
+// sample (eg. SIUN, UNIT with one unit per axis)
+func NewStrings(key string, ss ...string) (*Node, error) {
+	width := 1
+	for _, s := range ss {
+		if len(s) > width {
+			width = len(s)
+		}
+	}
+	if width > math.MaxUint8 {
+		return nil, errors.New("KLV: String too long")
+	}
+
+	data := make([]byte, width*len(ss))
+	for i, s := range ss {
+		copy(data[i*width:], s)
+	}
+
+	return &Node{
+		KLV:  KLV{FourCC: []byte(key), Format: 'c', Size: uint8(width), Count: uint16(len(ss))},
+		Data: data,
+	}, nil
+}
+
+// NewValues - element of format f whose samples are structures of perSample
+// values each, eg. NewValues("ACCL", 's', 3, x0, y0, z0, x1, y1, z1). See
+// encodeValue for the Go types accepted for each format.
+func NewValues(key string, f byte, perSample int, values ...interface{}) (*Node, error) {
+	size := formatSize(f) * perSample
+	if size == 0 || size > math.MaxUint8 {
+		return nil, errors.New("KLV: Invalid structure size")
+	}
+	if len(values)%perSample != 0 {
+		return nil, errors.New("KLV: Values do not fill the last sample")
+	}
+
+	data := make([]byte, 0, len(values)*formatSize(f))
+	for _, v := range values {
+		b, err := encodeValue(f, v)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, b...)
+	}
+
+	return newSamples(key, f, size, data)
+}
+
+// NewScale - SCAL element with one divisor per value, or one for all
+func NewScale(divisors ...int32) (*Node, error) {
+	values := make([]interface{}, len(divisors))
+	for i, d := range divisors {
+		values[i] = d
+	}
+	return NewValues("SCAL", 'l', 1, values...)
+}
+
+// NewComplex - '?' element of structures laid out by typ, which must also be
+// written to the stream as a TYPE element before it
+func NewComplex(key string, typ Type, samples [][]interface{}) (*Node, error) {
+	size := typ.Size()
+	if size > math.MaxUint8 {
+		return nil, errors.New("KLV: Invalid structure size")
+	}
+
+	data := make([]byte, 0, len(samples)*size)
+	for _, s := range samples {
+		if len(s) != len(typ) {
+			return nil, errors.New("TYPE: Wrong number of fields")
+		}
+		for i, f := range typ {
+			b, err := encodeValue(f, s[i])
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, b...)
+		}
+	}
+
+	return newSamples(key, '?', size, data)
+}
+
+func newSamples(key string, f byte, size int, data []byte) (*Node, error) {
+	count := len(data) / size
+	if count > math.MaxUint16 {
+		return nil, errors.New("KLV: Too many samples")
+	}
+
+	return &Node{
+		KLV:  KLV{FourCC: []byte(key), Format: f, Size: uint8(size), Count: uint16(count)},
+		Data: data,
+	}, nil
+}
+
+// encodeValue - one value as format f. Integer formats take any Go integer,
+// floats and fixed point any Go number, 'c' and 'F' a string of the right
+// length, 'G' a [16]byte and 'U' a time.Time.
+func encodeValue(f byte, v interface{}) ([]byte, error) {
+	b := make([]byte, formatSize(f))
+	if len(b) == 0 {
+		return nil, errors.New("KLV: Unsupported format character")
+	}
+
+	switch f {
+	case 'c', 'F':
+		s, ok := v.(string)
+		if !ok || len(s) != len(b) {
+			return nil, errors.New("KLV: Expected a string of " + strconv.Itoa(len(b)) + " characters")
+		}
+		copy(b, s)
+		return b, nil
+	case 'G':
+		id, ok := v.([16]byte)
+		if !ok {
+			return nil, errors.New("KLV: Expected a [16]byte")
+		}
+		copy(b, id[:])
+		return b, nil
+	case 'U':
+		t, ok := v.(time.Time)
+		if !ok {
+			return nil, errors.New("KLV: Expected a time.Time")
+		}
+		copy(b, t.UTC().Format("060102150405.000"))
+		return b, nil
+	case 'f', 'd', 'q', 'Q':
+		x, ok := toFloat64(v)
+		if !ok {
+			return nil, errors.New("KLV: Expected a number")
+		}
+		switch f {
+		case 'f':
+			binary.BigEndian.PutUint32(b, math.Float32bits(float32(x)))
+		case 'd':
+			binary.BigEndian.PutUint64(b, math.Float64bits(x))
+		case 'q':
+			binary.BigEndian.PutUint32(b, uint32(int32(math.Round(x*(1<<16)))))
+		case 'Q':
+			binary.BigEndian.PutUint64(b, uint64(int64(math.Round(x*(1<<32)))))
+		}
+		return b, nil
+	}
+
+	x, ok := toInt64(v)
+	if !ok {
+		return nil, errors.New("KLV: Expected an integer")
+	}
+	switch len(b) {
+	case 1:
+		b[0] = byte(x)
+	case 2:
+		binary.BigEndian.PutUint16(b, uint16(x))
+	case 4:
+		binary.BigEndian.PutUint32(b, uint32(x))
+	case 8:
+		binary.BigEndian.PutUint64(b, uint64(x))
+	}
+	return b, nil
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return int64(n), true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	i, ok := toInt64(v)
+	return float64(i), ok
+}
@@ -0,0 +1,81 @@
+package gpmf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Causes of decoding errors, for errors.Is
+var (
+	ErrInvalidKey    = errors.New("KLV: Invalid Four CC Character")
+	ErrInvalidFormat = errors.New("KLV: Invalid Format Character")
+	ErrOverrun       = errors.New("KLV: Element runs past the end of its container")
+	ErrTypeMismatch  = errors.New("TYPE: Structure size does not match")
+
+	// ErrTruncated - the stream ended part way through an element. Errors
+	// reporting where are TruncatedError.
+	ErrTruncated = errors.New("KLV: Truncated stream")
+)
+
+// ErrInvalidLength - a sample is not the size its stream needs. Errors
+// giving the sizes are LengthError.
+var ErrInvalidLength = errors.New("Invalid length packet")
+
+// LengthError - a sample of the stream FourCC has the wrong size
+type LengthError struct {
+	FourCC string
+	Want   int // bytes
+	Got    int
+}
+
+func (e *LengthError) Error() string {
+	return "Invalid length " + e.FourCC + " packet"
+}
+
+func (e *LengthError) Is(target error) bool {
+	return target == ErrInvalidLength
+}
+
+// Error - a problem with the element at Offset. Err is the cause, so
+// errors.Is and errors.As see through to it.
+type Error struct {
+	Offset int64    // of the element's header from the start of the stream
+	Path   []string // keys of the containers down to the element, eg. DEVC, STRM, ACCL
+	Want   int      // bytes expected, when the problem is one of size
+	Got    int      // bytes found
+	Err    error
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("offset %d: %s", e.Offset, e.Err)
+	if len(e.Path) > 0 {
+		msg = strings.Join(e.Path, "/") + " at " + msg
+	}
+	if e.Want != 0 || e.Got != 0 {
+		msg += fmt.Sprintf(" (want %d bytes, got %d)", e.Want, e.Got)
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// TruncatedError - the stream ended part way through the element at Offset,
+// the innermost one cut short when it was inside a container
+type TruncatedError struct {
+	Offset int64  // of the element's header from the start of the stream
+	FourCC string // key of the element, empty if its header was cut short
+}
+
+func (e *TruncatedError) Error() string {
+	if e.FourCC == "" {
+		return fmt.Sprintf("KLV: Truncated header at offset %d", e.Offset)
+	}
+	return fmt.Sprintf("KLV: Truncated %s payload at offset %d", e.FourCC, e.Offset)
+}
+
+func (e *TruncatedError) Is(target error) bool {
+	return target == ErrTruncated
+}
@@ -3,8 +3,6 @@ package gpmf
 import (
 	"encoding/binary"
 	"errors"
-	"math"
-	"time"
 )
 
 var fourCC = [][4]byte{
@@ -47,7 +45,8 @@ var formats = []byte{
 	0x71, // "q": 32-bit Q Number Q15.16
 	0x51, // "Q": 64-bit Q Number Q31.32
 	0x55, // "U": 16-byte UTC Date and Time string
-	0x3F, // "?": 32-bit unsigned integer Nested metadata
+	0x3F, // "?": Complex structure described by a preceding TYPE
+	0x00, // null: Nested metadata (DEVC, STRM)
 }
 
 // KLV - Go-Pro-Metadata-Format Key-Length-Value
@@ -58,59 +57,6 @@ type KLV struct {
 	Count  uint16 // Number of object
 }
 
-// SCAL - Slice of divisors for scaling data
-type SCAL struct {
-	Divisor []int
-}
-
-// ACCL - 3-axis accelerometer measurements (meters/sec^2)
-type ACCL struct {
-	X float64 `json:"accl_x"`
-	Y float64 `json:"accl_y"`
-	Z float64 `json:"accl_z"`
-}
-
-// GYRO - 3-axis gyroscope measurement (radians/sec)
-type GYRO struct {
-	X float64 `json:"gyro_x"`
-	Y float64 `json:"gyro_y"`
-	Z float64 `json:"gyro_z"`
-}
-
-// GPS5 - GPS data (latitude/longitude/altitude/speed/3D/time)
-type GPS5 struct {
-	Lat     float64 `json:"lat"`    // Latitude (Degrees)
-	Lon     float64 `json:"lon"`    // Longitude (Degrees)
-	Alt     float64 `json:"alt"`    // Altitude
-	Speed2D float64 `json:"spd_2d"` // m/s
-	Speed3D float64 `json:"spd_3d"` // m/s
-}
-
-// GPSF - GPS fix (0: No Fix, 2: 2D, 3: 3D)
-type GPSF struct {
-	Fix uint32 `json:"gps_fix"`
-}
-
-// GPSP - GPS position accuracy (centimeters)
-type GPSP struct {
-	Accuracy uint16 `json:"gps_accuracy"`
-}
-
-// GPSU - GPS acquired UTC timestamp
-type GPSU struct {
-	Time time.Time
-}
-
-// TMPC - Temperature (Degrees C)
-type TMPC struct {
-	Temp float32
-}
-
-// TSMP - Total number of samples
-type TSMP struct {
-	Samples uint32
-}
-
 // Parse (KLV) - Parse byte slice into KLV struct
 func (klv *KLV) Parse(bytes []byte) error {
 	// Check length
@@ -118,11 +64,13 @@ func (klv *KLV) Parse(bytes []byte) error {
 		return errors.New("KLV: Invalid packet length")
 	}
 
-	// Four CC
+	// Four CC: letters, digits, space, '.' or '_' (eg. "GPS5", "HD5.")
 	klv.FourCC = bytes[0:4]
 	for _, c := range klv.FourCC {
-		if c < 0x41 || c > 0x5A {
-			return errors.New("KLV: Invalid Four CC Character")
+		valid := (c >= 0x41 && c <= 0x5A) || (c >= 0x61 && c <= 0x7A) ||
+			(c >= 0x30 && c <= 0x39) || c == 0x20 || c == 0x2E || c == 0x5F
+		if !valid {
+			return ErrInvalidKey
 		}
 	}
 
@@ -133,7 +81,7 @@ func (klv *KLV) Parse(bytes []byte) error {
 		hasFormat = hasFormat || (f == klv.Format)
 	}
 	if !hasFormat {
-		return errors.New("KLV: Invalid Format Character")
+		return ErrInvalidFormat
 	}
 
 	// Size & Count
@@ -143,161 +91,3 @@ func (klv *KLV) Parse(bytes []byte) error {
 	// No error
 	return nil
 }
-
-// Parse (SCAL) - Parse byte slice into SCAL struct
-func (scale *SCAL) Parse(bytes []byte, size int64) error {
-	// Number of bytes per divisor
-	s := int(size)
-
-	// No left over bytes
-	if 0 != len(bytes)%s {
-		return errors.New("SCAL: Invalid packet length")
-	}
-
-	// Two or Four byte scalars
-	if s == 2 {
-		for i := 0; i < len(bytes); i += 2 {
-			scale.Divisor = append(scale.Divisor, int(binary.BigEndian.Uint16(bytes[i:i+2])))
-		}
-	} else if s == 4 {
-		for i := 0; i < len(bytes); i += 4 {
-			scale.Divisor = append(scale.Divisor, int(binary.BigEndian.Uint32(bytes[i:i+4])))
-		}
-	} else {
-		return errors.New("SCAL: Invalid packet length")
-	}
-
-	// No error
-	return nil
-}
-
-// Parse (ACCL) - Parse byte slice into ACCL struct and scale
-func (accl *ACCL) Parse(bytes []byte, scale *SCAL) error {
-	// Check length
-	if 6 != len(bytes) {
-		return errors.New("ACCL: Invalid packet length")
-	}
-
-	// Accelerometer 3D
-	accl.X = float64(int16(binary.BigEndian.Uint16(bytes[0:2]))) / float64(scale.Divisor[0])
-	accl.Y = float64(int16(binary.BigEndian.Uint16(bytes[2:4]))) / float64(scale.Divisor[0])
-	accl.Z = float64(int16(binary.BigEndian.Uint16(bytes[4:6]))) / float64(scale.Divisor[0])
-
-	// No error
-	return nil
-}
-
-// Parse (GYRO) - Parse byte slice into GYRO struct and scale
-func (gyro *GYRO) Parse(bytes []byte, scale *SCAL) error {
-	// Check length
-	if 6 != len(bytes) {
-		return errors.New("GYRO: Invalid packet length")
-	}
-
-	// Gyroscope 3D
-	gyro.X = float64(int16(binary.BigEndian.Uint16(bytes[0:2]))) / float64(scale.Divisor[0])
-	gyro.Y = float64(int16(binary.BigEndian.Uint16(bytes[2:4]))) / float64(scale.Divisor[0])
-	gyro.Z = float64(int16(binary.BigEndian.Uint16(bytes[4:6]))) / float64(scale.Divisor[0])
-
-	// No error
-	return nil
-}
-
-// Parse (GPS5) - Parse byte slice into GPS5 struct and scale
-func (gps5 *GPS5) Parse(bytes []byte, scale *SCAL) error {
-	// Check length
-	if 20 != len(bytes) {
-		return errors.New("GPS5: Inavlid packet length")
-	}
-
-	// Geodetic location
-	gps5.Lat = float64(int32(binary.BigEndian.Uint32(bytes[0:4]))) / float64(scale.Divisor[0])
-	gps5.Lon = float64(int32(binary.BigEndian.Uint32(bytes[4:8]))) / float64(scale.Divisor[1])
-	gps5.Alt = float64(int32(binary.BigEndian.Uint32(bytes[8:12]))) / float64(scale.Divisor[2])
-
-	// Speed 2D/3D
-	gps5.Speed2D = float64(int32(binary.BigEndian.Uint32(bytes[12:16]))) / float64(scale.Divisor[3])
-	gps5.Speed3D = float64(int32(binary.BigEndian.Uint32(bytes[16:20]))) / float64(scale.Divisor[4])
-
-	// No error
-	return nil
-}
-
-// Parse (GPSF) - Parse byte slice into GPSF struct
-func (gpsf *GPSF) Parse(bytes []byte) error {
-	// Check length
-	if 4 != len(bytes) {
-		return errors.New("GPSF: Invalid packet length")
-	}
-
-	// GPS fix
-	gpsf.Fix = binary.BigEndian.Uint32(bytes[0:4])
-
-	// No error
-	return nil
-}
-
-// Parse (GPSP) - Parse byte slice into GPSP struct
-func (gpsp *GPSP) Parse(bytes []byte) error {
-	// Check length
-	if 2 != len(bytes) {
-		return errors.New("GPSP: Invalid packet length")
-	}
-
-	// GPS accuracy
-	gpsp.Accuracy = binary.BigEndian.Uint16(bytes[0:2])
-
-	// No error
-	return nil
-}
-
-// Parse (GPSU) - Parse byte slice int GPSU struct
-func (gpsu *GPSU) Parse(bytes []byte) error {
-	// Check length
-	if 16 != len(bytes) {
-		return errors.New("GPSU: Invalid packet length")
-	}
-
-	// Parse timestamp ("06": Year, "01": Zero Month, "02": Zero Day, "15": Hour, "04": Zero Minute, "05": Zero Second)
-	t, err := time.Parse("060102150405", string(bytes))
-	if err != nil {
-		return err
-	}
-
-	// GPS timestamp
-	gpsu.Time = t
-
-	// No error
-	return nil
-}
-
-// Parse (TMPC) - Parse byte slice int TMPC struct
-func (tmpc *TMPC) Parse(bytes []byte) error {
-	// Check length
-	if 4 != len(bytes) {
-		return errors.New("TMPC: Invalid packet length")
-	}
-
-	// Extract bits
-	bits := binary.BigEndian.Uint32(bytes[0:4])
-
-	// Extract 32 bit float
-	tmpc.Temp = math.Float32frombits(bits)
-
-	// No error
-	return nil
-}
-
-// Parse (TSMP) - Parse byte slice int TSMP struct
-func (tsmp *TSMP) Parse(bytes []byte) error {
-	// Check length
-	if 4 != len(bytes) {
-		return errors.New("Invalid length TSMP packet")
-	}
-
-	// Total number of sample
-	tsmp.Samples = binary.BigEndian.Uint32(bytes[0:4])
-
-	// No error
-	return nil
-}
@@ -1,5 +1,17 @@
 package gpmf
 
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
 var scalKLV = []byte{
 	// "SCAL"
 	0x53, 0x43, 0x41, 0x4C,
@@ -34,3 +46,435 @@ var gyroKLV = []byte{
 	0xF5, 0xA2, 0xF3, 0x23, 0xFC, 0xA7,
 	0xF5, 0xF6, 0xF3, 0x30, 0xFC, 0xD7,
 }
+
+// nest - wrap payloads in a container KLV
+func nest(key string, payloads ...[]byte) []byte {
+	var body []byte
+	for _, p := range payloads {
+		body = append(body, p...)
+	}
+	header := []byte{key[0], key[1], key[2], key[3], 0x00, 0x01, byte(len(body) >> 8), byte(len(body))}
+	return append(header, body...)
+}
+
+func TestParseTree(t *testing.T) {
+	stream := nest("DEVC", nest("STRM", scalKLV, gyroKLV))
+
+	nodes, err := Parse(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0].Key() != "DEVC" {
+		t.Fatalf("expected a single DEVC, got %v", nodes)
+	}
+
+	strm := nodes[0].Find("STRM")
+	if strm == nil || len(strm.Children) != 2 {
+		t.Fatal("expected STRM with two children")
+	}
+
+	gyro := strm.Find("GYRO")
+	if path := strings.Join(gyro.Path, "/"); path != "DEVC/STRM/GYRO" {
+		t.Errorf("GYRO found at %s", path)
+	}
+	if gyro.Format != 's' || gyro.Size != 6 || gyro.Count != 6 {
+		t.Errorf("bad GYRO header %c/%d/%d", gyro.Format, gyro.Size, gyro.Count)
+	}
+	if len(gyro.Samples()) != 6 {
+		t.Errorf("expected 6 GYRO samples, got %d", len(gyro.Samples()))
+	}
+
+	node, err := ReadNode(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Find("STRM").Find("SCAL") == nil {
+		t.Error("ReadNode did not decode nested SCAL")
+	}
+}
+
+func TestReadTruncated(t *testing.T) {
+	devc := nest("DEVC", nest("STRM", scalKLV), nest("STRM", gyroKLV))
+	stream := append(append([]byte{}, scalKLV...), devc[:len(devc)-10]...)
+
+	r := NewReader(bytes.NewReader(stream))
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+
+	node, err := r.Next()
+	var truncated *TruncatedError
+	if !errors.As(err, &truncated) || !errors.Is(err, ErrTruncated) {
+		t.Fatalf("got %v, want a TruncatedError", err)
+	}
+	// the GYRO was cut, in the second STRM of the DEVC
+	gyroAt := int64(len(scalKLV) + 8 + len(nest("STRM", scalKLV)) + 8)
+	if truncated.Offset != gyroAt || truncated.FourCC != "GYRO" {
+		t.Errorf("truncated %s at %d, want GYRO at %d", truncated.FourCC, truncated.Offset, gyroAt)
+	}
+
+	// the first stream survived, the second lost its GYRO
+	if node == nil || len(node.Children) != 2 || node.Children[0].Find("SCAL") == nil {
+		t.Fatalf("partial DEVC %v", node)
+	}
+	if node.Children[1].Find("GYRO") != nil {
+		t.Error("kept a cut short GYRO")
+	}
+
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("got %v after the truncation, want io.EOF", err)
+	}
+}
+
+func TestReadLenient(t *testing.T) {
+	devc := nest("DEVC", nest("STRM", scalKLV, gyroKLV))
+
+	// the GYRO key of the middle device is damaged
+	bad := append([]byte{}, devc...)
+	bad[16+len(scalKLV)] = 0xFF
+
+	stream := bytes.Join([][]byte{devc, bad, devc}, nil)
+
+	r := NewReader(bytes.NewReader(stream))
+	r.Lenient = true
+
+	for i := 0; i < 2; i++ {
+		node, err := r.Next()
+		if err != nil {
+			t.Fatalf("device %d: %v", i, err)
+		}
+		if node.Find("STRM").Find("GYRO") == nil {
+			t.Errorf("device %d lost its GYRO", i)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("got %v at the end, want io.EOF", err)
+	}
+
+	if len(r.Skipped) != 1 || r.Skipped[0].Offset != int64(len(devc)) || r.Skipped[0].Length != int64(len(bad)) {
+		t.Errorf("skipped %+v, want the middle device", r.Skipped)
+	}
+
+	// a device whose count is damaged, so it seems to run past the end
+	long := append([]byte{}, devc...)
+	long[6], long[7] = 0xFF, 0xFF
+
+	stream = bytes.Join([][]byte{devc, long, devc, devc}, nil)
+	r = NewReader(bytes.NewReader(stream))
+	r.Lenient = true
+
+	for i := 0; i < 3; i++ {
+		if _, err := r.Next(); err != nil {
+			t.Fatalf("device %d: %v", i, err)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("got %v at the end, want io.EOF", err)
+	}
+	if len(r.Skipped) != 1 || r.Skipped[0].Offset != int64(len(devc)) || r.Skipped[0].Length != int64(len(long)) {
+		t.Errorf("skipped %+v, want the damaged device", r.Skipped)
+	}
+
+	// but a stream really cut short is still a truncation
+	r = NewReader(bytes.NewReader(bytes.Join([][]byte{devc, devc[:len(devc)-4]}, nil)))
+	r.Lenient = true
+	r.Next()
+	if node, err := r.Next(); !errors.Is(err, ErrTruncated) || node == nil {
+		t.Errorf("got %v, %v at the cut, want the partial device and a truncation", node, err)
+	}
+
+	// nor is a failure to read skipped over
+	failed := errors.New("disk failure")
+	r = NewReader(io.MultiReader(bytes.NewReader(devc), iotest.ErrReader(failed)))
+	r.Lenient = true
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err != failed || len(r.Skipped) != 0 {
+		t.Errorf("got %v, skipping %+v, want the read error", err, r.Skipped)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	bad := append([]byte{}, gyroKLV...)
+	bad[0] = 0xFF
+
+	_, err := Parse(nest("DEVC", nest("STRM", scalKLV, bad)))
+	var e *Error
+	if !errors.Is(err, ErrInvalidKey) || !errors.As(err, &e) {
+		t.Fatalf("got %v, want an invalid key Error", err)
+	}
+	if e.Offset != int64(16+len(scalKLV)) || len(e.Path) != 2 || e.Path[1] != "STRM" {
+		t.Errorf("error at %d in %v", e.Offset, e.Path)
+	}
+
+	// a GYRO claiming more samples than its stream holds
+	long := append([]byte{}, gyroKLV...)
+	long[7] = 0x07
+
+	_, err = Parse(nest("DEVC", nest("STRM", long)))
+	if !errors.Is(err, ErrOverrun) || !errors.As(err, &e) {
+		t.Fatalf("got %v, want an overrun Error", err)
+	}
+	if e.Want != 42 || e.Got != 36 || e.Path[2] != "GYRO" {
+		t.Errorf("%v wants %d bytes, got %d", e.Path, e.Want, e.Got)
+	}
+}
+
+func TestParseUnknownKey(t *testing.T) {
+	unknown := []byte{'Z', 'Z', '9', '9', 'L', 0x04, 0x00, 0x01, 0x00, 0x00, 0x00, 0x2A}
+
+	nodes, err := Parse(nest("DEVC", nest("STRM", unknown)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nodes[0].Children[0].Find("ZZ99") == nil {
+		t.Error("unknown key was not kept in the tree")
+	}
+}
+
+func TestComplex(t *testing.T) {
+	typ, err := ParseType("Lf[2]S")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(typ) != "LffS" || typ.Size() != 14 {
+		t.Fatalf("got type %q of size %d", typ, typ.Size())
+	}
+
+	face := []byte{
+		// "FACE" complex, 14 bytes, 1 sample
+		0x46, 0x41, 0x43, 0x45, 0x3F, 0x0E, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x07, // L: 7
+		0x3F, 0x00, 0x00, 0x00, // f: 0.5
+		0x3E, 0x80, 0x00, 0x00, // f: 0.25
+		0x00, 0x64, // S: 100
+		0x00, 0x00, // padding
+	}
+
+	nodes, err := Parse(face)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples, err := nodes[0].Complex(typ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 1 {
+		t.Fatalf("got %d samples", len(samples))
+	}
+
+	s := samples[0]
+	if s[0] != uint32(7) || s[1] != float32(0.5) || s[2] != float32(0.25) || s[3] != uint16(100) {
+		t.Errorf("got values %v", s)
+	}
+
+	if floats, err := nodes[0].ComplexFloats(typ); err != nil || floats[0][1] != 0.5 || floats[0][3] != 100 {
+		t.Errorf("got floats %v, %v", floats, err)
+	}
+	named, _ := ParseType("c[4]L[2]S")
+	if _, err := nodes[0].ComplexFloats(named); !errors.Is(err, ErrWrongFormat) {
+		t.Errorf("got %v for a structure holding text", err)
+	}
+
+	if _, err := ParseType("f[2"); err == nil {
+		t.Error("expected an error for an unterminated array")
+	}
+	for _, def := range []string{"b[2000000000]", "f[63]L[2]", "b[256]"} {
+		if _, err := ParseType(def); err == nil {
+			t.Errorf("expected an error for %s, larger than a sample can be", def)
+		}
+	}
+	if typ, err := ParseType("b[255]"); err != nil || typ.Size() != 255 {
+		t.Errorf("b[255] parsed as %d bytes, %v", typ.Size(), err)
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	stream := nest("DEVC", nest("STRM", scalKLV, gyroKLV))
+
+	nodes, err := Parse(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := NewEncoder(&out).Encode(nodes[0]); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), stream) {
+		t.Errorf("re-encoded stream differs:\n%x\n%x", out.Bytes(), stream)
+	}
+}
+
+func TestEncodeBuild(t *testing.T) {
+	scal, err := NewScale(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	siun, err := NewStrings("SIUN", "m/s²")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accl, err := NewValues("ACCL", 's', 3, 98, 0, -5, 97, 1, -4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	when := time.Date(2017, 3, 1, 12, 0, 0, 500000000, time.UTC)
+	gpsu, err := NewValues("GPSU", 'U', 1, when)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devc := NewContainer("DEVC",
+		NewContainer("STRM", NewString("STNM", "Accelerometer"), siun, scal, accl),
+		NewContainer("STRM", gpsu),
+	)
+
+	var out bytes.Buffer
+	if err := NewEncoder(&out).Encode(devc); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len()%4 != 0 {
+		t.Errorf("stream of %d bytes is not 32-bit aligned", out.Len())
+	}
+
+	node, err := ReadNode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	strm := node.Children[0]
+	if string(strm.Find("STNM").Data) != "Accelerometer" {
+		t.Errorf("got STNM %q", strm.Find("STNM").Data)
+	}
+	if a := strm.Find("ACCL"); a.Size != 6 || a.Count != 2 {
+		t.Errorf("got ACCL of size %d and count %d", a.Size, a.Count)
+	}
+
+	u := node.Children[1].Find("GPSU")
+	v, err := decodeValue('U', u.Data)
+	if err != nil || !v.(time.Time).Equal(when) {
+		t.Errorf("got GPSU %v, %v", v, err)
+	}
+}
+
+func TestValues(t *testing.T) {
+	nodes, err := Parse(append(append([]byte{}, scalKLV...), gyroKLV...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := nodes[0].Values()
+	if ints, ok := v.([]int64); err != nil || !ok || len(ints) != 5 || ints[0] != 10000000 {
+		t.Errorf("SCAL values %v, %v", v, err)
+	}
+
+	floats, err := nodes[1].Floats()
+	if err != nil || len(floats) != 18 || floats[0] != -3609 {
+		t.Errorf("GYRO as floats %v, %v", floats, err)
+	}
+
+	if _, err := nodes[1].Times(); !errors.Is(err, ErrWrongFormat) {
+		t.Errorf("got %v for times of a GYRO", err)
+	}
+
+	// Q15.16 and Q31.32 fixed point
+	q, _ := NewValues("QQQQ", 'q', 1, 1.5, -0.25)
+	Q, _ := NewValues("QQQQ", 'Q', 1, 2.75)
+	if v, _ := q.Floats(); len(v) != 2 || v[0] != 1.5 || v[1] != -0.25 {
+		t.Errorf("q values %v", v)
+	}
+	if v, _ := Q.Values(); v.([]float64)[0] != 2.75 {
+		t.Errorf("Q values %v", v)
+	}
+
+	// unsigned values past the int64 range are only read as such
+	big, _ := NewValues("BIGJ", 'J', 1, uint64(math.MaxUint64))
+	if _, err := big.Ints(); !errors.Is(err, ErrWrongFormat) {
+		t.Errorf("got %v for J as ints", err)
+	}
+	if v, _ := big.Values(); v.([]uint64)[0] != math.MaxUint64 {
+		t.Errorf("J values %v", v)
+	}
+
+	// a name as the camera writes it: one sample as wide as the string
+	if v, _ := NewString("STNM", "Gyroscope").Values(); v != "Gyroscope" {
+		t.Errorf("STNM values %q", v)
+	}
+
+	units, _ := NewStrings("SIUN", "rad/s", "m")
+	if v, _ := units.Values(); len(v.([]string)) != 2 || v.([]string)[1] != "m" {
+		t.Errorf("SIUN values %q", v)
+	}
+
+	when := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	gpsu, _ := NewValues("GPSU", 'U', 1, when)
+	if v, _ := gpsu.Times(); len(v) != 1 || !v[0].Equal(when) {
+		t.Errorf("GPSU values %v", v)
+	}
+}
+
+func TestSensors(t *testing.T) {
+	nodes, err := Parse(append(append([]byte{}, scalKLV...), gyroKLV...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scale := SCAL{}
+	if err := scale.Parse(nodes[0].Data, int64(nodes[0].Size)); err != nil {
+		t.Fatal(err)
+	}
+
+	// five divisors for three axes
+	gyro := GYRO{}
+	if err := gyro.Parse(nodes[1].Samples()[0], &scale); err == nil {
+		t.Error("GYRO scaled by a GPS5 SCAL")
+	}
+
+	gps := GPS5{}
+	sample := []byte{0x05, 0xF5, 0xE1, 0x00, 0, 0, 0, 0, 0, 0, 0x03, 0xE8, 0, 0, 0, 0, 0, 0, 0, 0}
+	if err := gps.Parse(sample, &scale); err != nil {
+		t.Fatal(err)
+	}
+	if gps.Latitude != 10 || gps.Altitude != 1 {
+		t.Errorf("GPS5 at %v, %v", gps.Latitude, gps.Altitude)
+	}
+
+	// the fields and calls of the old API still work
+	if gps.Lat != gps.Latitude || gps.Alt != gps.Altitude || gps.Speed2D != gps.Speed || scale.Divisor[0] != 10000000 {
+		t.Errorf("deprecated GPS5 fields %v, %v", gps.Lat, gps.Alt)
+	}
+	old := SCAL{Divisor: []int{100}}
+	accl := ACCL{}
+	if err := accl.Parse([]byte{0, 100, 0, 200, 0xFF, 0x9C}, &old); err != nil || accl.Y != 2 || accl.Z != -1 {
+		t.Errorf("ACCL scaled by Divisor gave %+v, %v", accl, err)
+	}
+	tsmp := TSMP{}
+	if err := tsmp.Parse([]byte{0, 0, 1, 0}, &old); err != nil || tsmp.Samples != 256 {
+		t.Errorf("TSMP %d, %v", tsmp.Samples, err)
+	}
+	fix := GPSF{}
+	if err := fix.Parse([]byte{0, 0, 0, 3}); err != nil || fix.F != 3 || fix.Fix != 3 {
+		t.Errorf("GPSF %+v, %v", fix, err)
+	}
+
+	// but they are left out of the JSON, which is the telemetry package's
+	tests := []struct {
+		v    interface{}
+		want string
+	}{
+		{accl, `{"X":1,"Y":2,"Z":-1,"media_time":0,"utc":0}`},
+		{fix, `{"F":3}`},
+		{GPSP{Accuracy: 250}, `{"Accuracy":250}`},
+	}
+	for _, test := range tests {
+		if b, _ := json.Marshal(test.v); string(b) != test.want {
+			t.Errorf("%T marshalled as %s, want %s", test.v, b, test.want)
+		}
+	}
+	if b, _ := json.Marshal(gps); strings.Contains(string(b), "spd_2d") || strings.Contains(string(b), `"Lat"`) {
+		t.Errorf("GPS5 marshalled as %s", b)
+	}
+}
@@ -0,0 +1,397 @@
+package gpmf
+
+import (
+	"errors"
+	"io"
+)
+
+// Node - one KLV element of a GPMF stream with its payload, and the nested
+// elements when it is a container (DEVC, STRM)
+type Node struct {
+	KLV
+	Data     []byte   // Payload of Size * Count bytes, without padding
+	Children []*Node  // Nested elements, only for containers
+	Offset   int64    // Of the header, from the start of the stream or buffer
+	Path     []string // Keys from the top level down to this one, eg. DEVC/STRM/ACCL; nil unless decoded
+}
+
+// Key - the Four CC as a string
+func (n *Node) Key() string {
+	return string(n.FourCC)
+}
+
+// IsNested - report whether the payload is itself a list of KLVs
+func (n *Node) IsNested() bool {
+	return n.Format == 0x00
+}
+
+// Find - first direct child with the given key, or nil
+func (n *Node) Find(key string) *Node {
+	for _, c := range n.Children {
+		if c.Key() == key {
+			return c
+		}
+	}
+	return nil
+}
+
+// Filter - drop the direct children of n for which keep is false, returning
+// how many were removed
+func (n *Node) Filter(keep func(*Node) bool) int {
+	kept := n.Children[:0]
+	for _, c := range n.Children {
+		if keep(c) {
+			kept = append(kept, c)
+		}
+	}
+
+	removed := len(n.Children) - len(kept)
+	n.Children = kept
+	return removed
+}
+
+// Samples - the payload split into Count structures of Size bytes each
+func (n *Node) Samples() [][]byte {
+	size := int(n.Size)
+	if size == 0 {
+		return nil
+	}
+
+	samples := make([][]byte, 0, len(n.Data)/size)
+	for i := 0; i+size <= len(n.Data); i += size {
+		samples = append(samples, n.Data[i:i+size])
+	}
+	return samples
+}
+
+// Walk - call fn for n and every node below it, depth first, stopping at the
+// first error
+func (n *Node) Walk(fn func(*Node) error) error {
+	if err := fn(n); err != nil {
+		return err
+	}
+	for _, c := range n.Children {
+		if err := c.Walk(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parse - decode a buffer of sibling KLVs, descending into containers.
+// Errors are *Error, placing the problem in the buffer.
+func Parse(bytes []byte) ([]*Node, error) {
+	return parse(bytes, 0, nil)
+}
+
+// parse - Parse for a buffer found at base in the stream, inside the
+// containers on path
+func parse(bytes []byte, base int64, path []string) ([]*Node, error) {
+	var nodes []*Node
+
+	for off := 0; off < len(bytes); {
+		at := base + int64(off)
+		if len(bytes)-off < 8 {
+			return nil, &Error{Offset: at, Path: path, Want: 8, Got: len(bytes) - off, Err: ErrOverrun}
+		}
+
+		n := &Node{Offset: at}
+		if err := n.KLV.Parse(bytes[off : off+8]); err != nil {
+			return nil, &Error{Offset: at, Path: path, Err: err}
+		}
+		n.Path = n.path(path)
+		off += 8
+
+		// Payload, padded to 32 bits
+		length := n.Length()
+		if off+length > len(bytes) {
+			return nil, &Error{Offset: at, Path: n.Path, Want: length, Got: len(bytes) - off, Err: ErrOverrun}
+		}
+		n.Data = bytes[off : off+length]
+		off += padded(length)
+
+		if err := n.parseChildren(); err != nil {
+			return nil, err
+		}
+
+		nodes = append(nodes, n)
+	}
+
+	return nodes, nil
+}
+
+// ReadNode - read the next top level KLV and everything nested in it from r,
+// returning io.EOF when r is exhausted
+func ReadNode(r io.Reader) (*Node, error) {
+	return NewReader(r).Next()
+}
+
+// Reader - reads top level KLVs one after another from a stream
+type Reader struct {
+	r      io.Reader
+	Offset int64 // bytes consumed so far
+	Last   int64 // where the element last returned by Next starts
+
+	// Lenient - on corrupt data, scan forward to the next DEVC and carry on
+	// from there instead of failing, noting what was passed over in Skipped
+	Lenient bool
+	Skipped []Skip
+
+	pending []byte // read ahead while scanning, not yet consumed
+}
+
+// Skip - a region of the stream passed over in lenient mode
+type Skip struct {
+	Offset int64 // of the first byte skipped
+	Length int64
+	Err    error // what was wrong with it
+}
+
+// NewReader - reader of the stream r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// Next - the next top level KLV and everything nested in it, or io.EOF when
+// the stream is exhausted.
+//
+// When the stream ends part way through a container the error is a
+// TruncatedError, returned along with what could be made of it: a node
+// holding every complete element that was read.
+//
+// In lenient mode only DEVC containers are returned; anything else at the
+// top level is treated as corrupt and skipped. So is an element running past
+// the end of the stream when another DEVC starts inside it, as its length
+// must be corrupt. Errors reading the underlying stream are returned as they
+// are, as there is nothing to skip.
+func (r *Reader) Next() (*Node, error) {
+	for {
+		start := r.Offset
+		n, raw, err := r.next()
+
+		if r.Lenient && err == nil && "DEVC" != n.Key() {
+			err = &Error{Offset: start, Path: n.Path, Err: errors.New("KLV: Expected DEVC")}
+		}
+
+		var derr *Error
+		corrupt := errors.As(err, &derr) || errors.Is(err, ErrTruncated)
+		if !r.Lenient || !corrupt || len(raw) == 0 {
+			r.Last = start
+			return n, err
+		}
+
+		// look for a device header anywhere past the start of the bad one
+		r.unread(raw[1:])
+		skipped, serr := r.resync()
+
+		// a length running past the end may just be corrupt: it is only a
+		// truncation if no device follows
+		if errors.Is(err, ErrTruncated) && serr != nil {
+			r.Last = start
+			return n, err
+		}
+
+		r.Skipped = append(r.Skipped, Skip{Offset: start, Length: 1 + skipped, Err: err})
+		if serr != nil {
+			return nil, serr
+		}
+	}
+}
+
+// SkipLast - note the element last returned by Next as skipped in lenient
+// mode, eg. when its contents could not be decoded
+func (r *Reader) SkipLast(err error) {
+	r.Skipped = append(r.Skipped, Skip{Offset: r.Last, Length: r.Offset - r.Last, Err: err})
+}
+
+// next - read one element, also returning the raw bytes consumed for it
+func (r *Reader) next() (*Node, []byte, error) {
+	start := r.Offset
+
+	header := make([]byte, 8)
+	read, err := r.readFull(header)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, header[:read], &TruncatedError{Offset: start}
+		}
+		return nil, nil, err
+	}
+
+	n := &Node{Offset: start}
+	if err := n.KLV.Parse(header); err != nil {
+		return nil, header, &Error{Offset: start, Err: err}
+	}
+	n.Path = n.path(nil)
+
+	// Payload and padding
+	length := n.Length()
+	buf := make([]byte, padded(length))
+	read, err = r.readFull(buf)
+	raw := append(header, buf[:read]...)
+	if err != nil {
+		// The last element of a stream may omit its padding
+		if err != io.ErrUnexpectedEOF && err != io.EOF {
+			return nil, raw, err
+		}
+		if read < length {
+			truncated := &TruncatedError{Offset: start, FourCC: n.Key()}
+			if !n.IsNested() {
+				return nil, raw, truncated
+			}
+			n.Data = buf[:read]
+			var inner *TruncatedError
+			if n.Children, inner = parsePartial(n.Data, start+8, n.Path); inner != nil {
+				truncated = inner
+			}
+			return n, raw, truncated
+		}
+	}
+	n.Data = buf[:length]
+
+	if err := n.parseChildren(); err != nil {
+		return nil, raw, err
+	}
+
+	return n, raw, nil
+}
+
+// readFull - io.ReadFull, taking bytes put back by unread first
+func (r *Reader) readFull(buf []byte) (int, error) {
+	read := copy(buf, r.pending)
+	r.pending = r.pending[read:]
+
+	more, err := io.ReadFull(r.r, buf[read:])
+	read += more
+	r.Offset += int64(read)
+
+	if read == len(buf) {
+		return read, nil
+	}
+	if read > 0 && err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return read, err
+}
+
+// unread - put bytes back to be read again
+func (r *Reader) unread(b []byte) {
+	r.pending = append(append([]byte{}, b...), r.pending...)
+	r.Offset -= int64(len(b))
+}
+
+// resync - consume bytes up to the next header of a DEVC, returning how
+// many were passed over, and io.EOF if the stream ran out first
+func (r *Reader) resync() (int64, error) {
+	var skipped int64
+	window := make([]byte, 0, 9)
+	b := make([]byte, 1)
+
+	for {
+		if _, err := r.readFull(b); err != nil {
+			return skipped + int64(len(window)), err
+		}
+
+		window = append(window, b[0])
+		if len(window) > 8 {
+			window = window[1:]
+			skipped++
+		}
+
+		if len(window) == 8 && isDevice(window) {
+			r.unread(window)
+			return skipped, nil
+		}
+	}
+}
+
+// isDevice - whether header is a valid DEVC header
+func isDevice(header []byte) bool {
+	klv := KLV{}
+	return "DEVC" == string(header[0:4]) && 0x00 == header[4] && nil == klv.Parse(header)
+}
+
+// parsePartial - the complete elements at the start of a cut short payload,
+// and whatever is complete inside a container that was cut short, along with
+// where the innermost element was cut: nil when that can't be told, eg. when
+// the bytes stop making sense before they run out. The payload is found at
+// base in the stream, inside the containers on path.
+func parsePartial(bytes []byte, base int64, path []string) ([]*Node, *TruncatedError) {
+	var nodes []*Node
+
+	off := 0
+	for off+8 <= len(bytes) {
+		n := &Node{Offset: base + int64(off)}
+		if err := n.KLV.Parse(bytes[off : off+8]); err != nil {
+			return nodes, nil
+		}
+		n.Path = n.path(path)
+		off += 8
+
+		length := n.Length()
+		if off+length > len(bytes) {
+			cut := &TruncatedError{Offset: n.Offset, FourCC: n.Key()}
+			if n.IsNested() {
+				n.Data = bytes[off:]
+				var inner *TruncatedError
+				if n.Children, inner = parsePartial(n.Data, n.Offset+8, n.Path); inner != nil {
+					cut = inner
+				}
+				nodes = append(nodes, n)
+			}
+			return nodes, cut
+		}
+		n.Data = bytes[off : off+length]
+		off += padded(length)
+
+		if err := n.parseChildren(); err != nil {
+			return nodes, nil
+		}
+
+		nodes = append(nodes, n)
+	}
+
+	// cut in a header
+	if off < len(bytes) {
+		return nodes, &TruncatedError{Offset: base + int64(off)}
+	}
+	return nodes, nil
+}
+
+// Length - number of payload bytes described by the header
+func (klv *KLV) Length() int {
+	return int(klv.Size) * int(klv.Count)
+}
+
+// parseChildren - decode the payload of a container
+func (n *Node) parseChildren() error {
+	if !n.IsNested() {
+		return nil
+	}
+
+	children, err := parse(n.Data, n.Offset+8, n.Path)
+	if err != nil {
+		return err
+	}
+	n.Children = children
+
+	return nil
+}
+
+// path - path extended with the key of n
+func (n *Node) path(path []string) []string {
+	return append(append([]string{}, path...), n.Key())
+}
+
+// where - the path of n for errors, just its key when it wasn't decoded
+// from a stream
+func (n *Node) where() []string {
+	if n.Path != nil {
+		return n.Path
+	}
+	return []string{n.Key()}
+}
+
+// padded - length rounded up to a multiple of 32 bits
+func padded(length int) int {
+	return (length + 3) &^ 3
+}
@@ -0,0 +1,276 @@
+package gpmf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// The sensor streams every camera records, parsed one sample at a time.
+// These are the types the telemetry package decodes into, with its field
+// names and JSON. The names this package used before are kept as fields
+// that Parse fills too, left out of the JSON.
+
+// SCAL - divisors turning the raw values of the samples that follow into
+// real units
+type SCAL struct {
+	Values []int
+
+	// Deprecated: use Values, which Parse fills too
+	Divisor []int `json:"-"`
+}
+
+// ACCL - 3-axis accelerometer in m/s²
+type ACCL struct {
+	X float64
+	Y float64
+	Z float64
+
+	MediaTime time.Duration `json:"media_time"` // since the start of the video
+	TS        int64         `json:"utc"`        // UTC in microseconds, zero without a GPS time
+}
+
+// GYRO - 3-axis gyroscope in rad/s
+type GYRO struct {
+	X float64
+	Y float64
+	Z float64
+
+	MediaTime time.Duration `json:"media_time"` // since the start of the video
+	TS        int64         `json:"utc"`        // UTC in microseconds, zero without a GPS time
+}
+
+// GPS5 - latitude, longitude, altitude, 2D and 3D speed
+type GPS5 struct {
+	Latitude  float64 `json:"lat"`    // degrees lat
+	Longitude float64 `json:"lon"`    // degrees lon
+	Altitude  float64 `json:"alt"`    // meters above wgs84 ellipsoid ?
+	Speed     float64 `json:"spd"`    // m/s
+	Speed3D   float64 `json:"spd_3d"` // m/s, standard error?
+	TS        int64   `json:"utc"`
+
+	MediaTime time.Duration `json:"media_time"` // since the start of the video
+
+	// Deprecated: use Latitude, Longitude, Altitude and Speed, which Parse
+	// fills too
+	Lat     float64 `json:"-"`
+	Lon     float64 `json:"-"`
+	Alt     float64 `json:"-"`
+	Speed2D float64 `json:"-"`
+}
+
+// GPSF - GPS fix (0: none, 2: 2D, 3: 3D)
+type GPSF struct {
+	F uint32
+
+	// Deprecated: use F, which Parse fills too
+	Fix uint32 `json:"-"`
+}
+
+// GPSP - GPS precision (dilution of precision × 100, under 500 is good)
+type GPSP struct {
+	Accuracy uint16
+}
+
+// GPSU - GPS acquired UTC time
+type GPSU struct {
+	Time time.Time
+}
+
+// TMPC - temperature in °C
+type TMPC struct {
+	Temp float32
+}
+
+// TSMP - total number of samples the stream has delivered
+type TSMP struct {
+	Samples uint32
+}
+
+// Parse (SCAL) - the divisors of a SCAL payload of 2 or 4 byte values,
+// replacing any held before
+func (scale *SCAL) Parse(bytes []byte, size int64) error {
+	s := int(size)
+	if s != 2 && s != 4 {
+		return errors.New("Unknown SCAL length")
+	}
+	if 0 != len(bytes)%s {
+		// the next whole number of values
+		return &LengthError{FourCC: "SCAL", Want: len(bytes) + s - len(bytes)%s, Got: len(bytes)}
+	}
+
+	scale.Values = scale.Values[:0]
+	for i := 0; i < len(bytes); i += s {
+		if s == 2 {
+			scale.Values = append(scale.Values, int(binary.BigEndian.Uint16(bytes[i:i+s])))
+		} else {
+			scale.Values = append(scale.Values, int(binary.BigEndian.Uint32(bytes[i:i+s])))
+		}
+	}
+	scale.Divisor = append(scale.Divisor[:0], scale.Values...)
+
+	return nil
+}
+
+// Apply - divide raw sensor values by the scale: one divisor per value when
+// SCAL has as many entries as there are values, or a single divisor for all
+// of them. A stream without SCAL is unscaled. Divisor is used when Values
+// is empty, so that scales made by older code still apply.
+func (scale *SCAL) Apply(raw ...float64) ([]float64, error) {
+	divisors := scale.Values
+	if len(divisors) == 0 {
+		divisors = scale.Divisor
+	}
+
+	n := len(divisors)
+	if n != 0 && n != 1 && n != len(raw) {
+		return nil, fmt.Errorf("SCAL has %d divisors for %d values", n, len(raw))
+	}
+
+	values := make([]float64, len(raw))
+	for i, v := range raw {
+		divisor := 1
+		if n == 1 {
+			divisor = divisors[0]
+		} else if n > 1 {
+			divisor = divisors[i]
+		}
+
+		if divisor == 0 {
+			return nil, errors.New("Zero SCAL divisor")
+		}
+
+		values[i] = v / float64(divisor)
+	}
+
+	return values, nil
+}
+
+// Parse (ACCL) - one scaled sample
+func (accl *ACCL) Parse(bytes []byte, scale *SCAL) error {
+	v, err := axes("ACCL", bytes, scale)
+	if err != nil {
+		return err
+	}
+
+	accl.X, accl.Y, accl.Z = v[0], v[1], v[2]
+
+	return nil
+}
+
+// Parse (GYRO) - one scaled sample
+func (gyro *GYRO) Parse(bytes []byte, scale *SCAL) error {
+	v, err := axes("GYRO", bytes, scale)
+	if err != nil {
+		return err
+	}
+
+	gyro.X, gyro.Y, gyro.Z = v[0], v[1], v[2]
+
+	return nil
+}
+
+// axes - the three scaled 16-bit values of a 3-axis sensor sample
+func axes(key string, bytes []byte, scale *SCAL) ([]float64, error) {
+	if 6 != len(bytes) {
+		return nil, &LengthError{FourCC: key, Want: 6, Got: len(bytes)}
+	}
+
+	return scale.Apply(
+		float64(int16(binary.BigEndian.Uint16(bytes[0:2]))),
+		float64(int16(binary.BigEndian.Uint16(bytes[2:4]))),
+		float64(int16(binary.BigEndian.Uint16(bytes[4:6]))),
+	)
+}
+
+// Parse (GPS5) - one scaled sample
+func (gps *GPS5) Parse(bytes []byte, scale *SCAL) error {
+	if 20 != len(bytes) {
+		return &LengthError{FourCC: "GPS5", Want: 20, Got: len(bytes)}
+	}
+
+	raw := make([]float64, 5)
+	for i := range raw {
+		raw[i] = float64(int32(binary.BigEndian.Uint32(bytes[4*i : 4*i+4])))
+	}
+
+	// altitude from mm, speeds from mm/s
+	v, err := scale.Apply(raw...)
+	if err != nil {
+		return err
+	}
+
+	gps.Latitude = v[0]
+	gps.Longitude = v[1]
+	gps.Altitude = v[2]
+	gps.Speed = v[3]
+	gps.Speed3D = v[4]
+
+	gps.Lat, gps.Lon, gps.Alt, gps.Speed2D = gps.Latitude, gps.Longitude, gps.Altitude, gps.Speed
+
+	return nil
+}
+
+// Parse (GPSF) - the fix
+func (gpsf *GPSF) Parse(bytes []byte) error {
+	if 4 != len(bytes) {
+		return &LengthError{FourCC: "GPSF", Want: 4, Got: len(bytes)}
+	}
+
+	gpsf.F = binary.BigEndian.Uint32(bytes[0:4])
+	gpsf.Fix = gpsf.F
+
+	return nil
+}
+
+// Parse (GPSP) - the precision
+func (gpsp *GPSP) Parse(bytes []byte) error {
+	if 2 != len(bytes) {
+		return &LengthError{FourCC: "GPSP", Want: 2, Got: len(bytes)}
+	}
+
+	gpsp.Accuracy = binary.BigEndian.Uint16(bytes[0:2])
+
+	return nil
+}
+
+// Parse (GPSU) - the time, from "yymmddhhmmss.sss"
+func (gpsu *GPSU) Parse(bytes []byte) error {
+	if 16 != len(bytes) {
+		return &LengthError{FourCC: "GPSU", Want: 16, Got: len(bytes)}
+	}
+
+	t, err := time.Parse("060102150405", string(bytes))
+	if err != nil {
+		return err
+	}
+
+	gpsu.Time = t
+
+	return nil
+}
+
+// Parse (TMPC) - the temperature
+func (tmpc *TMPC) Parse(bytes []byte) error {
+	if 4 != len(bytes) {
+		return &LengthError{FourCC: "TMPC", Want: 4, Got: len(bytes)}
+	}
+
+	tmpc.Temp = math.Float32frombits(binary.BigEndian.Uint32(bytes[0:4]))
+
+	return nil
+}
+
+// Parse (TSMP) - the sample count. TSMP is never scaled; the optional scale
+// is accepted only so older callers keep compiling.
+func (tsmp *TSMP) Parse(bytes []byte, _ ...*SCAL) error {
+	if 4 != len(bytes) {
+		return &LengthError{FourCC: "TSMP", Want: 4, Got: len(bytes)}
+	}
+
+	tsmp.Samples = binary.BigEndian.Uint32(bytes[0:4])
+
+	return nil
+}
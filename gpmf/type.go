@@ -0,0 +1,197 @@
+package gpmf
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type - field formats of a complex structure, as given by a TYPE key, with
+// arrays expanded ("f[3]L" is 'f', 'f', 'f', 'L')
+type Type []byte
+
+// ParseType - parse a TYPE definition such as "Lffff" or "f[4]S". A
+// structure is one sample, so no larger than the 255 bytes a KLV size allows.
+func ParseType(def string) (Type, error) {
+	def = strings.TrimRight(def, "\x00 ")
+
+	var t Type
+	size := 0
+	for i := 0; i < len(def); i++ {
+		f := def[i]
+		if formatSize(f) == 0 {
+			return nil, errors.New("TYPE: Unsupported format character " + strconv.Quote(string(f)))
+		}
+
+		// optional array length
+		repeat := 1
+		if i+1 < len(def) && def[i+1] == '[' {
+			end := strings.IndexByte(def[i:], ']')
+			if end < 0 {
+				return nil, errors.New("TYPE: Unterminated array")
+			}
+			n, err := strconv.Atoi(def[i+2 : i+end])
+			if err != nil || n < 1 {
+				return nil, errors.New("TYPE: Invalid array length")
+			}
+			repeat = n
+			i += end
+		}
+
+		if repeat > math.MaxUint8 || size+repeat*formatSize(f) > math.MaxUint8 {
+			return nil, errors.New("TYPE: Structure larger than 255 bytes")
+		}
+		size += repeat * formatSize(f)
+
+		for j := 0; j < repeat; j++ {
+			t = append(t, f)
+		}
+	}
+
+	if len(t) == 0 {
+		return nil, errors.New("TYPE: Empty definition")
+	}
+
+	return t, nil
+}
+
+// Size - number of bytes in one structure
+func (t Type) Size() int {
+	size := 0
+	for _, f := range t {
+		size += formatSize(f)
+	}
+	return size
+}
+
+// Decode - split one structure into its field values (see decodeValue for
+// the Go type of each format)
+func (t Type) Decode(bytes []byte) ([]interface{}, error) {
+	if len(bytes) != t.Size() {
+		return nil, errors.New("TYPE: Invalid structure length")
+	}
+
+	values := make([]interface{}, len(t))
+	off := 0
+	for i, f := range t {
+		size := formatSize(f)
+		v, err := decodeValue(f, bytes[off:off+size])
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+		off += size
+	}
+
+	return values, nil
+}
+
+// Complex - decode every sample of n with the structure definition typ
+func (n *Node) Complex(typ Type) ([][]interface{}, error) {
+	if typ.Size() != int(n.Size) {
+		return nil, &Error{Offset: n.Offset, Path: n.where(), Want: typ.Size(), Got: int(n.Size), Err: ErrTypeMismatch}
+	}
+
+	var samples [][]interface{}
+	for _, b := range n.Samples() {
+		values, err := typ.Decode(b)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, values)
+	}
+
+	return samples, nil
+}
+
+// ComplexFloats - the samples of n as Complex decodes them, every field read
+// as a number, for structures of numeric fields only
+func (n *Node) ComplexFloats(typ Type) ([][]float64, error) {
+	samples, err := n.Complex(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	floats := make([][]float64, len(samples))
+	for i, s := range samples {
+		floats[i] = make([]float64, len(s))
+		for j, v := range s {
+			f, ok := toFloat64(v)
+			if !ok {
+				return nil, n.wrongFormat()
+			}
+			floats[i][j] = f
+		}
+	}
+
+	return floats, nil
+}
+
+// formatSize - bytes taken by one value of format f, zero when f has no
+// fixed size
+func formatSize(f byte) int {
+	switch f {
+	case 'b', 'B', 'c':
+		return 1
+	case 's', 'S':
+		return 2
+	case 'l', 'L', 'f', 'F', 'q':
+		return 4
+	case 'd', 'j', 'J', 'Q':
+		return 8
+	case 'G', 'U':
+		return 16
+	}
+	return 0
+}
+
+// decodeValue - one value of format f: int8, uint8, int16, uint16, int32,
+// uint32, int64 or uint64 for integers, float32 or float64 for floats and
+// fixed point, string for 'c' and 'F', [16]byte for 'G' and time.Time for 'U'
+func decodeValue(f byte, b []byte) (interface{}, error) {
+	if len(b) != formatSize(f) {
+		return nil, errors.New("TYPE: Invalid value length")
+	}
+
+	switch f {
+	case 'b':
+		return int8(b[0]), nil
+	case 'B':
+		return b[0], nil
+	case 'c':
+		return string(b), nil
+	case 's':
+		return int16(binary.BigEndian.Uint16(b)), nil
+	case 'S':
+		return binary.BigEndian.Uint16(b), nil
+	case 'l':
+		return int32(binary.BigEndian.Uint32(b)), nil
+	case 'L':
+		return binary.BigEndian.Uint32(b), nil
+	case 'f':
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), nil
+	case 'd':
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case 'F':
+		return string(b), nil
+	case 'G':
+		var id [16]byte
+		copy(id[:], b)
+		return id, nil
+	case 'j':
+		return int64(binary.BigEndian.Uint64(b)), nil
+	case 'J':
+		return binary.BigEndian.Uint64(b), nil
+	case 'q':
+		return float64(int32(binary.BigEndian.Uint32(b))) / (1 << 16), nil
+	case 'Q':
+		return float64(int64(binary.BigEndian.Uint64(b))) / (1 << 32), nil
+	case 'U':
+		return time.Parse("060102150405", string(b))
+	}
+
+	return nil, errors.New("TYPE: Unsupported format character")
+}
@@ -0,0 +1,175 @@
+package gpmf
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrWrongFormat - the element's format doesn't hold the kind of value asked
+// for
+var ErrWrongFormat = errors.New("KLV: Format does not hold this kind of value")
+
+// UUID - a 'G' value
+type UUID [16]byte
+
+func (id UUID) String() string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
+
+// Values - every value of n decoded by its format, whatever the stream:
+//
+//	b s l j     []int64
+//	B S L J     []uint64
+//	f d q Q     []float64, fixed point (Q15.16, Q31.32) included
+//	c           string, or []string with one per sample when there are several
+//	F           []string of Four CCs
+//	G           []UUID
+//	U           []time.Time
+//
+// Containers and '?' structures (see Complex) are not plain values.
+func (n *Node) Values() (interface{}, error) {
+	switch n.Format {
+	case 'b', 's', 'l', 'j':
+		return n.Ints()
+	case 'B', 'S', 'L', 'J':
+		return n.Uints()
+	case 'f', 'd', 'q', 'Q':
+		return n.Floats()
+	case 'c':
+		if n.Size > 1 && n.Count > 1 {
+			return n.Strings()
+		}
+		return n.Text()
+	case 'F':
+		return n.Strings()
+	case 'G':
+		return n.UUIDs()
+	case 'U':
+		return n.Times()
+	}
+	return nil, n.wrongFormat()
+}
+
+// Ints - the values of a signed integer element. Unsigned ones, which may
+// not fit, are read with Uints.
+func (n *Node) Ints() ([]int64, error) {
+	var ints []int64
+	err := n.each("bslj", func(v interface{}) {
+		i, _ := toInt64(v)
+		ints = append(ints, i)
+	})
+	return ints, err
+}
+
+// Uints - the values of an unsigned integer element
+func (n *Node) Uints() ([]uint64, error) {
+	var uints []uint64
+	err := n.each("BSLJ", func(v interface{}) {
+		switch u := v.(type) {
+		case uint8:
+			uints = append(uints, uint64(u))
+		case uint16:
+			uints = append(uints, uint64(u))
+		case uint32:
+			uints = append(uints, uint64(u))
+		case uint64:
+			uints = append(uints, u)
+		}
+	})
+	return uints, err
+}
+
+// Floats - the values of any numeric element, so that streams this package
+// knows nothing about can still be read as numbers
+func (n *Node) Floats() ([]float64, error) {
+	var floats []float64
+	err := n.each("bBsSlLjJfdqQ", func(v interface{}) {
+		f, _ := toFloat64(v)
+		floats = append(floats, f)
+	})
+	return floats, err
+}
+
+// Text - the payload of a 'c' element as one string, without zero padding
+func (n *Node) Text() (string, error) {
+	if n.Format != 'c' {
+		return "", n.wrongFormat()
+	}
+
+	end := len(n.Data)
+	for end > 0 && n.Data[end-1] == 0 {
+		end--
+	}
+	return string(n.Data[:end]), nil
+}
+
+// Strings - one string per sample of a 'c' element, without zero padding,
+// or the Four CCs of an 'F' element
+func (n *Node) Strings() ([]string, error) {
+	if n.Format != 'c' && n.Format != 'F' {
+		return nil, n.wrongFormat()
+	}
+
+	size := int(n.Size)
+	if n.Format == 'F' {
+		size = 4
+	}
+
+	var ss []string
+	for i := 0; size > 0 && i+size <= len(n.Data); i += size {
+		s := n.Data[i : i+size]
+		for len(s) > 0 && s[len(s)-1] == 0 {
+			s = s[:len(s)-1]
+		}
+		ss = append(ss, string(s))
+	}
+	return ss, nil
+}
+
+// UUIDs - the values of a 'G' element
+func (n *Node) UUIDs() ([]UUID, error) {
+	var ids []UUID
+	err := n.each("G", func(v interface{}) {
+		ids = append(ids, v.([16]byte))
+	})
+	return ids, err
+}
+
+// Times - the values of a 'U' element, in UTC
+func (n *Node) Times() ([]time.Time, error) {
+	var times []time.Time
+	err := n.each("U", func(v interface{}) {
+		times = append(times, v.(time.Time))
+	})
+	return times, err
+}
+
+// each - decode the values of n one by one, if its format is one of formats
+func (n *Node) each(formats string, fn func(v interface{})) error {
+	ok := false
+	for i := 0; i < len(formats); i++ {
+		ok = ok || formats[i] == n.Format
+	}
+	if !ok {
+		return n.wrongFormat()
+	}
+
+	size := formatSize(n.Format)
+	if 0 != len(n.Data)%size {
+		return &Error{Offset: n.Offset, Path: n.where(), Err: errors.New("KLV: Payload is not a whole number of values")}
+	}
+
+	for i := 0; i < len(n.Data); i += size {
+		v, err := decodeValue(n.Format, n.Data[i:i+size])
+		if err != nil {
+			return &Error{Offset: n.Offset, Path: n.where(), Err: err}
+		}
+		fn(v)
+	}
+	return nil
+}
+
+func (n *Node) wrongFormat() error {
+	return &Error{Offset: n.Offset, Path: n.where(), Err: ErrWrongFormat}
+}
@@ -0,0 +1,87 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// box - header of an ISO-BMFF box and where it sits in the file
+type box struct {
+	Type   string // four character box type
+	Offset int64  // offset of the box header
+	Header int64  // length of the header (8 or 16 bytes)
+	Size   int64  // total length including the header
+}
+
+// body returns the offset and length of the box payload
+func (b box) body() (int64, int64) {
+	return b.Offset + b.Header, b.Size - b.Header
+}
+
+// readBoxes - list the sibling boxes found between start and end
+func readBoxes(r io.ReaderAt, start, end int64) ([]box, error) {
+	var boxes []box
+	hdr := make([]byte, 16)
+
+	for off := start; off+8 <= end; {
+		if _, err := r.ReadAt(hdr[0:8], off); err != nil {
+			return nil, err
+		}
+
+		b := box{
+			Type:   string(hdr[4:8]),
+			Offset: off,
+			Header: 8,
+			Size:   int64(binary.BigEndian.Uint32(hdr[0:4])),
+		}
+
+		switch b.Size {
+		case 0:
+			// box extends to the end of its parent
+			b.Size = end - off
+		case 1:
+			// 64-bit largesize follows the type
+			if _, err := r.ReadAt(hdr[8:16], off+8); err != nil {
+				return nil, err
+			}
+			b.Header = 16
+			b.Size = int64(binary.BigEndian.Uint64(hdr[8:16]))
+		}
+
+		if b.Size < b.Header || off+b.Size > end {
+			return nil, errors.New("MP4: Invalid box size")
+		}
+
+		boxes = append(boxes, b)
+		off += b.Size
+	}
+
+	return boxes, nil
+}
+
+// children - list the boxes nested inside a container box
+func children(r io.ReaderAt, parent box) ([]box, error) {
+	off, n := parent.body()
+	return readBoxes(r, off, off+n)
+}
+
+// find - first box of the given type, or false if there is none
+func find(boxes []box, typ string) (box, bool) {
+	for _, b := range boxes {
+		if b.Type == typ {
+			return b, true
+		}
+	}
+	return box{}, false
+}
+
+// payload - read the whole payload of a leaf box
+func payload(r io.ReaderAt, b box) ([]byte, error) {
+	off, n := b.body()
+	buf := make([]byte, n)
+	if _, err := r.ReadAt(buf, off); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
@@ -0,0 +1,119 @@
+package mp4
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Chapter - one file of a recording the camera split across files, every
+// 4GB or so
+type Chapter struct {
+	Name      string // the file
+	Recording string // the same for every chapter of a recording
+	Number    int    // 1 for the first file
+}
+
+var (
+	// GH010001, GX020001, ...: encoding, chapter and recording number
+	chapterName = regexp.MustCompile(`^G([A-Z])(\d\d)(\d{4})$`)
+	// GOPR0001 then GP010001, GP020001, ... on cameras up to the Hero6
+	firstChapterName = regexp.MustCompile(`^GOPR(\d{4})$`)
+)
+
+// ParseChapter - the chapter a file is by its name, eg. GH020001.MP4 is the
+// second of recording 0001, as is GP010001.MP4 from older cameras whose first
+// is GOPR0001.MP4. ok is false for names not of this form.
+func ParseChapter(name string) (c Chapter, ok bool) {
+	ext := filepath.Ext(name)
+	base := strings.ToUpper(strings.TrimSuffix(filepath.Base(name), ext))
+
+	// files of different kinds or folders are never chapters of each other
+	recording := func(prefix, clip string) string {
+		return filepath.Join(filepath.Dir(name), prefix+clip) + strings.ToLower(ext)
+	}
+
+	if m := firstChapterName.FindStringSubmatch(base); m != nil {
+		return Chapter{Name: name, Recording: recording("GP", m[1]), Number: 1}, true
+	}
+
+	m := chapterName.FindStringSubmatch(base)
+	if m == nil {
+		return Chapter{}, false
+	}
+
+	number, _ := strconv.Atoi(m[2])
+	if m[1] == "P" {
+		// GP01 follows GOPR
+		number++
+	} else if number == 0 {
+		return Chapter{}, false
+	}
+
+	return Chapter{Name: name, Recording: recording("G"+m[1], m[3]), Number: number}, true
+}
+
+// Chapters - group files into recordings, each in chapter order. Recordings
+// come in the order of their first file in names; a file not named like a
+// chapter is a recording of its own.
+func Chapters(names []string) [][]string {
+	var recordings [][]Chapter
+	index := map[string]int{}
+
+	for _, name := range names {
+		c, ok := ParseChapter(name)
+		if !ok {
+			recordings = append(recordings, []Chapter{{Name: name}})
+			continue
+		}
+
+		i, seen := index[c.Recording]
+		if !seen {
+			i = len(recordings)
+			index[c.Recording] = i
+			recordings = append(recordings, nil)
+		}
+		recordings[i] = append(recordings[i], c)
+	}
+
+	groups := make([][]string, len(recordings))
+	for i, chapters := range recordings {
+		sort.SliceStable(chapters, func(a, b int) bool { return chapters[a].Number < chapters[b].Number })
+		for _, c := range chapters {
+			groups[i] = append(groups[i], c.Name)
+		}
+	}
+
+	return groups
+}
+
+// FindChapters - every chapter of the recording name belongs to, from the
+// files beside it, in order. A name not of a chapter is returned on its own.
+func FindChapters(name string) ([]string, error) {
+	c, ok := ParseChapter(name)
+	if !ok {
+		return []string{name}, nil
+	}
+
+	dir := filepath.Dir(name)
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{name}
+	for _, info := range infos {
+		other := filepath.Join(dir, info.Name())
+		if info.IsDir() || filepath.Clean(other) == filepath.Clean(name) {
+			continue
+		}
+		if o, ok := ParseChapter(other); ok && o.Recording == c.Recording {
+			names = append(names, other)
+		}
+	}
+
+	return Chapters(names)[0], nil
+}
@@ -0,0 +1,173 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+// u32s - big endian 32-bit words
+func u32s(vals ...uint32) []byte {
+	b := make([]byte, 4*len(vals))
+	for i, v := range vals {
+		binary.BigEndian.PutUint32(b[4*i:], v)
+	}
+	return b
+}
+
+// testFile - an MP4 with one gpmd track holding the given samples, each one
+// second long at a timescale of 1000
+func testFile(samples ...[]byte) []byte {
+	ftyp := mkbox("ftyp", []byte("mp41"), u32s(0))
+	mdat := mkbox("mdat", samples...)
+
+	sizes := []uint32{0, uint32(len(samples))}
+	offsets := []uint32{uint32(len(samples))}
+	off := uint32(len(ftyp) + 8)
+	for _, s := range samples {
+		sizes = append(sizes, uint32(len(s)))
+		offsets = append(offsets, off)
+		off += uint32(len(s))
+	}
+
+	hdlr := mkbox("hdlr", u32s(0, 0), []byte("meta"), u32s(0, 0, 0), []byte("\tGoPro MET\x00"))
+	mdhd := mkbox("mdhd", u32s(0, 0, 0, 1000, 1000*uint32(len(samples))), u32s(0))
+	stsd := mkbox("stsd", u32s(0, 1), mkbox("gpmd", u32s(0, 1)))
+	stts := mkbox("stts", u32s(0, 1, uint32(len(samples)), 1000))
+	stsc := mkbox("stsc", u32s(0, 1, 1, 1, 1))
+	stsz := mkbox("stsz", u32s(0), u32s(sizes...))
+	stco := mkbox("stco", u32s(0), u32s(offsets...))
+
+	stbl := mkbox("stbl", stsd, stts, stsc, stsz, stco)
+	mdia := mkbox("mdia", mdhd, hdlr, mkbox("minf", stbl))
+	moov := mkbox("moov", mkbox("trak", mdia))
+
+	return bytes.Join([][]byte{ftyp, mdat, moov}, nil)
+}
+
+func TestOpen(t *testing.T) {
+	file := testFile([]byte("DEVC0001"), []byte("DEVC000000000002"))
+	r := bytes.NewReader(file)
+
+	if !Sniff(r) {
+		t.Fatal("Sniff did not recognise the file")
+	}
+
+	track, err := Open(r, int64(len(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(track.Samples) != 2 {
+		t.Fatalf("got %d samples, want 2", len(track.Samples))
+	}
+	if track.Time(1) != time.Second || track.Duration(1) != time.Second {
+		t.Errorf("sample 1 at %v for %v, want 1s for 1s", track.Time(1), track.Duration(1))
+	}
+
+	data, err := ioutil.ReadAll(track.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "DEVC0001DEVC000000000002" {
+		t.Errorf("got payload %q", data)
+	}
+}
+
+func TestOpenNoMetadata(t *testing.T) {
+	file := mkbox("ftyp", []byte("mp41"))
+	file = append(file, mkbox("moov")...)
+
+	if _, err := Open(bytes.NewReader(file), int64(len(file))); err != ErrNoMetadata {
+		t.Errorf("got %v, want ErrNoMetadata", err)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	file := testFile([]byte("DEVC0001"), []byte("DEVC000000000002"))
+
+	var out bytes.Buffer
+	err := Rewrite(&out, bytes.NewReader(file), int64(len(file)), func(i int, payload []byte) ([]byte, error) {
+		if i == 0 {
+			return []byte("DEVC0000000000000001"), nil
+		}
+		return payload, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := bytes.NewReader(out.Bytes())
+	track, err := Open(r, int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(track.Samples) != 2 || track.Time(1) != time.Second {
+		t.Fatalf("timing changed: %d samples, second at %v", len(track.Samples), track.Time(1))
+	}
+
+	data, err := ioutil.ReadAll(track.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "DEVC0000000000000001DEVC000000000002" {
+		t.Errorf("got payload %q", data)
+	}
+
+	// ftyp and the original mdat header are untouched ahead of the new one
+	prefix := 16 + 8
+	if !bytes.Equal(out.Bytes()[:prefix], file[:prefix]) {
+		t.Error("leading boxes were modified")
+	}
+
+	// but the old samples are gone, not just unreferenced
+	if !bytes.Equal(out.Bytes()[prefix:prefix+24], make([]byte, 24)) {
+		t.Errorf("old samples left as %q", out.Bytes()[prefix:prefix+24])
+	}
+	if bytes.Contains(out.Bytes(), []byte("DEVC0001")) {
+		t.Error("the replaced payload is still in the file")
+	}
+}
+
+func TestChapters(t *testing.T) {
+	got := Chapters([]string{
+		"GH020001.MP4", "GOPR0002.MP4", "GH010001.MP4", "notes.bin",
+		"GP010002.MP4", "GX010001.MP4", "GH010001.LRV",
+	})
+	want := [][]string{
+		{"GH010001.MP4", "GH020001.MP4"},
+		{"GOPR0002.MP4", "GP010002.MP4"},
+		{"notes.bin"},
+		{"GX010001.MP4"},
+		{"GH010001.LRV"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if strings.Join(got[i], " ") != strings.Join(want[i], " ") {
+			t.Errorf("recording %d is %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCorruptTables(t *testing.T) {
+	// counts far beyond what the tables or the file could hold
+	if _, err := parseSTSZ(u32s(0, 1, 0xFFFFFFFF), 1000); err == nil {
+		t.Error("fixed size stsz of 4G samples accepted")
+	}
+	if _, err := parseSTSZ(u32s(0, 0, 0xFFFFFFFF), 1000); err == nil {
+		t.Error("stsz of 4G sizes accepted")
+	}
+	if _, err := parseSTCO(u32s(0, 0xFFFFFFFF)); err == nil {
+		t.Error("stco of 4G offsets accepted")
+	}
+	if _, err := parseCO64(u32s(0, 0xFFFFFFFF)); err == nil {
+		t.Error("co64 of 4G offsets accepted")
+	}
+}
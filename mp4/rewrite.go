@@ -0,0 +1,320 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+)
+
+// Rewrite - copy the MP4/MOV src of the given size to dst with every sample of
+// the GoPro metadata track replaced by what edit returns for it.
+//
+// Video, audio and any other boxes are copied byte for byte. The new
+// metadata goes in an mdat after them, followed by a moov that points the
+// metadata track at it and the other tracks at their (possibly moved) data.
+// The old metadata samples are overwritten with zeroes where they were, so
+// nothing edited out of them survives in dst. Sample count and timing are
+// unchanged.
+func Rewrite(dst io.Writer, src io.ReaderAt, size int64, edit func(i int, payload []byte) ([]byte, error)) error {
+	track, err := Open(src, size)
+	if err != nil {
+		return err
+	}
+
+	payloads := make([][]byte, len(track.Samples))
+	for i := range track.Samples {
+		b, err := track.Payload(i)
+		if err != nil {
+			return err
+		}
+		if payloads[i], err = edit(i, b); err != nil {
+			return err
+		}
+	}
+
+	top, err := readBoxes(src, 0, size)
+	if err != nil {
+		return err
+	}
+	moov, _ := find(top, "moov")
+
+	// everything but the moov keeps its order, so data after the moov moves
+	// back by its size
+	moved := func(off int64) int64 {
+		if off >= moov.Offset+moov.Size {
+			return off - moov.Size
+		}
+		return off
+	}
+
+	// the old samples, to blank out as they are copied
+	old := make([]span, len(track.Samples))
+	for i, smp := range track.Samples {
+		old[i] = span{smp.Offset, smp.Size}
+	}
+	sort.Slice(old, func(i, j int) bool { return old[i].Offset < old[j].Offset })
+
+	for _, b := range top {
+		if b.Type == "moov" {
+			continue
+		}
+		if err := copyBox(dst, src, b, old); err != nil {
+			return err
+		}
+	}
+
+	// the new metadata
+	var data int64
+	for _, p := range payloads {
+		data += int64(len(p))
+	}
+	if 8+data > math.MaxUint32 {
+		return errors.New("MP4: Metadata too large")
+	}
+	mdat := make([]byte, 8)
+	binary.BigEndian.PutUint32(mdat[0:4], uint32(8+data))
+	copy(mdat[4:8], "mdat")
+	if _, err := dst.Write(mdat); err != nil {
+		return err
+	}
+
+	offsets := make([]int64, len(payloads))
+	off := size - moov.Size + 8
+	for i, p := range payloads {
+		offsets[i] = off
+		off += int64(len(p))
+		if _, err := dst.Write(p); err != nil {
+			return err
+		}
+	}
+
+	w := &moovWriter{r: src, track: track, moved: moved, offsets: offsets, payloads: payloads}
+	b, err := w.box(moov)
+	if err != nil {
+		return err
+	}
+
+	_, err = dst.Write(b)
+	return err
+}
+
+// span - a range of bytes of the file
+type span struct {
+	Offset, Size int64
+}
+
+// copyBox - copy a top level box unchanged, except that a box running to the
+// end of the file gets an explicit size now that more follows it, and the
+// bytes of its body in blank, sorted by offset, are written as zeroes
+func copyBox(dst io.Writer, src io.ReaderAt, b box, blank []span) error {
+	hdr := make([]byte, b.Header)
+	if _, err := src.ReadAt(hdr, b.Offset); err != nil {
+		return err
+	}
+
+	if binary.BigEndian.Uint32(hdr[0:4]) == 0 {
+		if b.Size > math.MaxUint32 {
+			return errors.New("MP4: Cannot resize box " + b.Type)
+		}
+		binary.BigEndian.PutUint32(hdr[0:4], uint32(b.Size))
+	}
+
+	if _, err := dst.Write(hdr); err != nil {
+		return err
+	}
+
+	return copyBlanking(dst, src, b.Offset+b.Header, b.Offset+b.Size, blank)
+}
+
+// copyBlanking - copy the bytes of src from start up to end, writing zeroes
+// in place of those in blank, which is sorted by offset
+func copyBlanking(dst io.Writer, src io.ReaderAt, start, end int64, blank []span) error {
+	zeroes := make([]byte, 32*1024)
+
+	pos := start
+	for _, s := range blank {
+		from, to := s.Offset, s.Offset+s.Size
+		if from < pos {
+			from = pos
+		}
+		if to > end {
+			to = end
+		}
+		if from >= to {
+			continue
+		}
+
+		if _, err := io.Copy(dst, io.NewSectionReader(src, pos, from-pos)); err != nil {
+			return err
+		}
+		for n := to - from; n > 0; {
+			chunk := int64(len(zeroes))
+			if n < chunk {
+				chunk = n
+			}
+			if _, err := dst.Write(zeroes[:chunk]); err != nil {
+				return err
+			}
+			n -= chunk
+		}
+		pos = to
+	}
+
+	_, err := io.Copy(dst, io.NewSectionReader(src, pos, end-pos))
+	return err
+}
+
+// moovWriter - rebuilds the moov for Rewrite
+type moovWriter struct {
+	r     io.ReaderAt
+	track *Track
+	moved func(int64) int64
+
+	// where the metadata samples now live
+	offsets  []int64
+	payloads [][]byte
+
+	inTrack     bool // below the metadata trak
+	wroteChunks bool // its chunk offset table is done
+}
+
+// boxes on the path to the chunk offset tables
+var containers = map[string]bool{"moov": true, "trak": true, "mdia": true, "minf": true, "stbl": true}
+
+// box - serialize b, rewriting sample tables on the way
+func (w *moovWriter) box(b box) ([]byte, error) {
+	if containers[b.Type] {
+		if b.Type == "trak" {
+			defer func(was bool) { w.inTrack = was }(w.inTrack)
+			w.inTrack = b.Offset == w.track.trak.Offset
+		}
+
+		kids, err := children(w.r, b)
+		if err != nil {
+			return nil, err
+		}
+
+		var body []byte
+		for _, k := range kids {
+			kb, err := w.box(k)
+			if err != nil {
+				return nil, err
+			}
+			body = append(body, kb...)
+		}
+		return mkbox(b.Type, body), nil
+	}
+
+	body, err := payload(w.r, b)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.inTrack {
+		switch b.Type {
+		case "stsz":
+			return w.stsz(), nil
+		case "stsc":
+			return w.stsc(body), nil
+		case "stco", "co64":
+			// whichever the track had, there is only ever one of them
+			if w.wroteChunks {
+				return nil, nil
+			}
+			w.wroteChunks = true
+			return w.chunks(), nil
+		}
+		return mkbox(b.Type, body), nil
+	}
+
+	switch b.Type {
+	case "stco":
+		if len(body) < 8 {
+			return nil, errors.New("MP4: Invalid stco length")
+		}
+		for i := 8; i+4 <= len(body); i += 4 {
+			off := w.moved(int64(binary.BigEndian.Uint32(body[i:])))
+			binary.BigEndian.PutUint32(body[i:], uint32(off))
+		}
+	case "co64":
+		if len(body) < 8 {
+			return nil, errors.New("MP4: Invalid co64 length")
+		}
+		for i := 8; i+8 <= len(body); i += 8 {
+			off := w.moved(int64(binary.BigEndian.Uint64(body[i:])))
+			binary.BigEndian.PutUint64(body[i:], uint64(off))
+		}
+	}
+
+	return mkbox(b.Type, body), nil
+}
+
+// stsz - the new sample sizes
+func (w *moovWriter) stsz() []byte {
+	body := make([]byte, 12+4*len(w.payloads))
+	binary.BigEndian.PutUint32(body[8:12], uint32(len(w.payloads)))
+	for i, p := range w.payloads {
+		binary.BigEndian.PutUint32(body[12+4*i:], uint32(len(p)))
+	}
+	return mkbox("stsz", body)
+}
+
+// stsc - one sample per chunk, keeping the sample description
+func (w *moovWriter) stsc(old []byte) []byte {
+	desc := uint32(1)
+	if len(old) >= 20 {
+		desc = binary.BigEndian.Uint32(old[16:20])
+	}
+
+	body := make([]byte, 20)
+	binary.BigEndian.PutUint32(body[4:8], 1)
+	binary.BigEndian.PutUint32(body[8:12], 1)
+	binary.BigEndian.PutUint32(body[12:16], 1)
+	binary.BigEndian.PutUint32(body[16:20], desc)
+	return mkbox("stsc", body)
+}
+
+// chunks - one chunk per sample at its new offset, 64-bit when needed
+func (w *moovWriter) chunks() []byte {
+	wide := len(w.offsets) > 0 && w.offsets[len(w.offsets)-1] > math.MaxUint32
+
+	if !wide {
+		body := make([]byte, 8+4*len(w.offsets))
+		binary.BigEndian.PutUint32(body[4:8], uint32(len(w.offsets)))
+		for i, off := range w.offsets {
+			binary.BigEndian.PutUint32(body[8+4*i:], uint32(off))
+		}
+		return mkbox("stco", body)
+	}
+
+	body := make([]byte, 8+8*len(w.offsets))
+	binary.BigEndian.PutUint32(body[4:8], uint32(len(w.offsets)))
+	for i, off := range w.offsets {
+		binary.BigEndian.PutUint64(body[8+8*i:], uint64(off))
+	}
+	return mkbox("co64", body)
+}
+
+// mkbox - serialize a box with a 32-bit or, when needed, 64-bit size
+func mkbox(typ string, parts ...[]byte) []byte {
+	var body []byte
+	for _, p := range parts {
+		body = append(body, p...)
+	}
+
+	size := 8 + int64(len(body))
+	if size <= math.MaxUint32 {
+		b := make([]byte, 8, size)
+		binary.BigEndian.PutUint32(b[0:4], uint32(size))
+		copy(b[4:8], typ)
+		return append(b, body...)
+	}
+
+	b := make([]byte, 16, size+8)
+	binary.BigEndian.PutUint32(b[0:4], 1)
+	copy(b[4:8], typ)
+	binary.BigEndian.PutUint64(b[8:16], uint64(size+8))
+	return append(b, body...)
+}
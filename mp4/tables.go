@@ -0,0 +1,184 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// parseTimescale - extract the timescale from an mdhd payload
+func parseTimescale(b []byte) (uint32, error) {
+	if len(b) < 4 {
+		return 0, errors.New("MP4: Invalid mdhd length")
+	}
+
+	// version 1 uses 64-bit creation and modification times
+	off := 12
+	if b[0] == 1 {
+		off = 20
+	}
+	if len(b) < off+4 {
+		return 0, errors.New("MP4: Invalid mdhd length")
+	}
+
+	return binary.BigEndian.Uint32(b[off : off+4]), nil
+}
+
+// parseSTSZ - sample sizes, of samples that must fit in a file of the given
+// size. The count is checked before anything is allocated for it, so a
+// corrupt table can't ask for gigabytes.
+func parseSTSZ(b []byte, size int64) ([]int64, error) {
+	if len(b) < 12 {
+		return nil, errors.New("MP4: Invalid stsz length")
+	}
+
+	fixed := binary.BigEndian.Uint32(b[4:8])
+	count := int64(binary.BigEndian.Uint32(b[8:12]))
+
+	if fixed != 0 {
+		if count > size/int64(fixed) {
+			return nil, errors.New("MP4: Invalid stsz sample count")
+		}
+		sizes := make([]int64, count)
+		for i := range sizes {
+			sizes[i] = int64(fixed)
+		}
+		return sizes, nil
+	}
+
+	if int64(len(b)) < 12+4*count {
+		return nil, errors.New("MP4: Invalid stsz length")
+	}
+	sizes := make([]int64, count)
+	for i := range sizes {
+		sizes[i] = int64(binary.BigEndian.Uint32(b[12+4*i:]))
+	}
+
+	return sizes, nil
+}
+
+// parseSTCO - 32-bit chunk offsets
+func parseSTCO(b []byte) ([]int64, error) {
+	if len(b) < 8 {
+		return nil, errors.New("MP4: Invalid stco length")
+	}
+
+	count := int64(binary.BigEndian.Uint32(b[4:8]))
+	if int64(len(b)) < 8+4*count {
+		return nil, errors.New("MP4: Invalid stco length")
+	}
+
+	offsets := make([]int64, count)
+	for i := range offsets {
+		offsets[i] = int64(binary.BigEndian.Uint32(b[8+4*i:]))
+	}
+
+	return offsets, nil
+}
+
+// parseCO64 - 64-bit chunk offsets
+func parseCO64(b []byte) ([]int64, error) {
+	if len(b) < 8 {
+		return nil, errors.New("MP4: Invalid co64 length")
+	}
+
+	count := int64(binary.BigEndian.Uint32(b[4:8]))
+	if int64(len(b)) < 8+8*count {
+		return nil, errors.New("MP4: Invalid co64 length")
+	}
+
+	offsets := make([]int64, count)
+	for i := range offsets {
+		offsets[i] = int64(binary.BigEndian.Uint64(b[8+8*i:]))
+	}
+
+	return offsets, nil
+}
+
+// parseSTSC - resolve the sample-to-chunk table into per-sample file offsets
+func parseSTSC(b []byte, chunks []int64, sizes []int64) ([]int64, error) {
+	offsets := make([]int64, len(sizes))
+
+	// no table: assume one sample per chunk
+	if b == nil {
+		if len(chunks) < len(sizes) {
+			return nil, errors.New("MP4: Missing chunk offsets")
+		}
+		copy(offsets, chunks)
+		return offsets, nil
+	}
+
+	if len(b) < 8 {
+		return nil, errors.New("MP4: Invalid stsc length")
+	}
+
+	count := int(binary.BigEndian.Uint32(b[4:8]))
+	if len(b) < 8+12*count {
+		return nil, errors.New("MP4: Invalid stsc length")
+	}
+
+	sample := 0
+	for i := 0; i < count; i++ {
+		entry := b[8+12*i:]
+		first := int(binary.BigEndian.Uint32(entry[0:4])) - 1
+		perChunk := int(binary.BigEndian.Uint32(entry[4:8]))
+
+		// the run lasts until the next entry's first chunk
+		last := len(chunks)
+		if i+1 < count {
+			last = int(binary.BigEndian.Uint32(b[8+12*(i+1):])) - 1
+		}
+		if first < 0 || last > len(chunks) {
+			return nil, errors.New("MP4: Invalid stsc chunk index")
+		}
+
+		for c := first; c < last; c++ {
+			off := chunks[c]
+			for j := 0; j < perChunk && sample < len(sizes); j++ {
+				offsets[sample] = off
+				off += sizes[sample]
+				sample++
+			}
+		}
+	}
+
+	if sample != len(sizes) {
+		return nil, errors.New("MP4: Sample table does not cover all samples")
+	}
+
+	return offsets, nil
+}
+
+// parseSTTS - decode times and durations of count samples
+func parseSTTS(b []byte, count int) ([]uint64, []uint32, error) {
+	times := make([]uint64, count)
+	durations := make([]uint32, count)
+
+	// without a time table every sample is left at zero
+	if b == nil {
+		return times, durations, nil
+	}
+
+	if len(b) < 8 {
+		return nil, nil, errors.New("MP4: Invalid stts length")
+	}
+
+	entries := int(binary.BigEndian.Uint32(b[4:8]))
+	if len(b) < 8+8*entries {
+		return nil, nil, errors.New("MP4: Invalid stts length")
+	}
+
+	sample := 0
+	var t uint64
+	for i := 0; i < entries; i++ {
+		n := int(binary.BigEndian.Uint32(b[8+8*i:]))
+		d := binary.BigEndian.Uint32(b[12+8*i:])
+		for j := 0; j < n && sample < count; j++ {
+			times[sample] = t
+			durations[sample] = d
+			t += uint64(d)
+			sample++
+		}
+	}
+
+	return times, durations, nil
+}
@@ -0,0 +1,258 @@
+package mp4
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// ErrNoMetadata - the file has no GoPro metadata track
+var ErrNoMetadata = errors.New("MP4: No GoPro metadata track")
+
+// Sample - location and timing of one metadata sample (a GPMF payload)
+type Sample struct {
+	Offset   int64  // absolute offset in the file
+	Size     int64  // length in bytes
+	Time     uint64 // decode time in track timescale units
+	Duration uint32 // duration in track timescale units
+}
+
+// Track - the "gpmd" track of an MP4/MOV file
+type Track struct {
+	Timescale uint32   // units per second of Sample.Time and Sample.Duration
+	Samples   []Sample // samples in decode order
+
+	r    io.ReaderAt
+	trak box
+}
+
+// Sniff - report whether r looks like an ISO-BMFF (MP4/MOV) file
+func Sniff(r io.ReaderAt) bool {
+	hdr := make([]byte, 8)
+	if _, err := r.ReadAt(hdr, 0); err != nil {
+		return false
+	}
+
+	switch string(hdr[4:8]) {
+	case "ftyp", "moov", "mdat", "free", "skip", "wide":
+		return true
+	}
+	return false
+}
+
+// Open - locate the GoPro metadata track in an MP4/MOV file of the given size
+func Open(r io.ReaderAt, size int64) (*Track, error) {
+	top, err := readBoxes(r, 0, size)
+	if err != nil {
+		return nil, err
+	}
+
+	moov, ok := find(top, "moov")
+	if !ok {
+		return nil, errors.New("MP4: Missing moov box")
+	}
+
+	traks, err := children(r, moov)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, trak := range traks {
+		if trak.Type != "trak" {
+			continue
+		}
+
+		t, err := readTrack(r, trak, size)
+		if err != nil {
+			return nil, err
+		}
+		if t != nil {
+			return t, nil
+		}
+	}
+
+	return nil, ErrNoMetadata
+}
+
+// Time - presentation time of sample i from the start of the media
+func (t *Track) Time(i int) time.Duration {
+	return t.duration(t.Samples[i].Time)
+}
+
+// Duration - length of sample i
+func (t *Track) Duration(i int) time.Duration {
+	return t.duration(uint64(t.Samples[i].Duration))
+}
+
+func (t *Track) duration(units uint64) time.Duration {
+	if t.Timescale == 0 {
+		return 0
+	}
+	sec := units / uint64(t.Timescale)
+	rem := units % uint64(t.Timescale)
+	return time.Duration(sec)*time.Second + time.Duration(rem)*time.Second/time.Duration(t.Timescale)
+}
+
+// Payload - the bytes of sample i
+func (t *Track) Payload(i int) ([]byte, error) {
+	s := t.Samples[i]
+	b := make([]byte, s.Size)
+	if _, err := t.r.ReadAt(b, s.Offset); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Reader - all samples concatenated, equivalent to extracting the track with
+// ffmpeg's rawvideo muxer
+func (t *Track) Reader() io.Reader {
+	readers := make([]io.Reader, len(t.Samples))
+	for i, s := range t.Samples {
+		readers[i] = io.NewSectionReader(t.r, s.Offset, s.Size)
+	}
+	return io.MultiReader(readers...)
+}
+
+// readTrack - build a Track from a trak box of a file of the given size, or
+// nil if it isn't GoPro metadata
+func readTrack(r io.ReaderAt, trak box, size int64) (*Track, error) {
+	mdia, err := descend(r, trak, "mdia")
+	if err != nil || mdia == nil {
+		return nil, err
+	}
+
+	mboxes, err := children(r, *mdia)
+	if err != nil {
+		return nil, err
+	}
+
+	stbl, err := descend(r, *mdia, "minf", "stbl")
+	if err != nil || stbl == nil {
+		return nil, err
+	}
+
+	sboxes, err := children(r, *stbl)
+	if err != nil {
+		return nil, err
+	}
+
+	// the sample description names the format, the handler is a fallback
+	// for muxers that rewrite it
+	isMeta := false
+	if stsd, ok := find(sboxes, "stsd"); ok {
+		b, err := payload(r, stsd)
+		if err != nil {
+			return nil, err
+		}
+		isMeta = len(b) >= 16 && string(b[12:16]) == "gpmd"
+	}
+	if hdlr, ok := find(mboxes, "hdlr"); ok && !isMeta {
+		b, err := payload(r, hdlr)
+		if err != nil {
+			return nil, err
+		}
+		isMeta = len(b) > 24 && bytes.Contains(b[24:], []byte("GoPro MET"))
+	}
+	if !isMeta {
+		return nil, nil
+	}
+
+	t := &Track{r: r, trak: trak}
+
+	mdhd, ok := find(mboxes, "mdhd")
+	if !ok {
+		return nil, errors.New("MP4: Missing mdhd box")
+	}
+	b, err := payload(r, mdhd)
+	if err != nil {
+		return nil, err
+	}
+	if t.Timescale, err = parseTimescale(b); err != nil {
+		return nil, err
+	}
+
+	tables := map[string][]byte{}
+	for _, typ := range []string{"stts", "stsc", "stsz", "stco", "co64"} {
+		if sb, ok := find(sboxes, typ); ok {
+			if tables[typ], err = payload(r, sb); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sizes, err := parseSTSZ(tables["stsz"], size)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []int64
+	if tables["co64"] != nil {
+		chunks, err = parseCO64(tables["co64"])
+	} else {
+		chunks, err = parseSTCO(tables["stco"])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	offsets, err := parseSTSC(tables["stsc"], chunks, sizes)
+	if err != nil {
+		return nil, err
+	}
+
+	times, durations, err := parseSTTS(tables["stts"], len(sizes))
+	if err != nil {
+		return nil, err
+	}
+
+	t.Samples = make([]Sample, len(sizes))
+	for i := range sizes {
+		t.Samples[i] = Sample{
+			Offset:   offsets[i],
+			Size:     sizes[i],
+			Time:     times[i],
+			Duration: durations[i],
+		}
+	}
+
+	return t, nil
+}
+
+// descend - follow a path of container types below parent, nil if absent
+func descend(r io.ReaderAt, parent box, path ...string) (*box, error) {
+	for _, typ := range path {
+		boxes, err := children(r, parent)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := find(boxes, typ)
+		if !ok {
+			return nil, nil
+		}
+		parent = b
+	}
+	return &parent, nil
+}
+
+// Telemetry - reader over the GPMF data in f: the metadata track when f is an
+// MP4/MOV file, otherwise f itself (an already extracted .bin, in which case
+// there is no Track to give payload timing)
+func Telemetry(f *os.File) (io.Reader, *Track, error) {
+	if !Sniff(f) {
+		return f, nil, nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t, err := Open(f, info.Size())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return t.Reader(), t, nil
+}
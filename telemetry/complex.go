@@ -0,0 +1,24 @@
+package telemetry
+
+import (
+	"github.com/stilldavid/gopro-utils/gpmf"
+)
+
+// Stream whose samples are structures described by a TYPE key (eg. FACE,
+// SCEN, MWET): one slice of unscaled field values per sample
+type COMPLEX struct {
+	Type    string
+	Samples [][]interface{}
+}
+
+func (c *COMPLEX) Parse(n *gpmf.Node, typ gpmf.Type) error {
+	samples, err := n.Complex(typ)
+	if err != nil {
+		return err
+	}
+
+	c.Type = string(typ)
+	c.Samples = append(c.Samples, samples...)
+
+	return nil
+}
@@ -0,0 +1,17 @@
+package telemetry
+
+import (
+	"time"
+)
+
+// Camera orientation relative to the first frame
+type CORI struct {
+	Quaternion
+
+	MediaTime time.Duration `json:"media_time"` // since the start of the video
+	TS        int64         `json:"utc"`        // UTC in microseconds, zero without a GPS time
+}
+
+func (cori *CORI) Parse(bytes []byte, scale *SCAL) error {
+	return cori.parse("CORI", bytes, scale)
+}
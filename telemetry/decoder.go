@@ -0,0 +1,224 @@
+package telemetry
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/stilldavid/gopro-utils/gpmf"
+	"github.com/stilldavid/gopro-utils/mp4"
+)
+
+// Decoder - reads the DEVC payloads of a telemetry stream one at a time, in
+// order, so files of any size can be processed without holding them in
+// memory
+type Decoder struct {
+	r      *gpmf.Reader
+	track  *mp4.Track
+	starts []int64 // offset of each of the track's samples in the stream
+	err    error
+
+	device string         // only payloads of this device, see Select
+	counts map[uint32]int // payloads read so far from each device, in this chapter
+
+	// later chapters of a split recording, see Append
+	chapters []chapter
+	offset   time.Duration // where the current chapter starts
+	end      time.Duration // of the last payload so far
+	skipped  []gpmf.Skip   // in the chapters already read
+
+	// running sample count (TSMP) of each device's streams so far, and what
+	// is added to those of the current chapter to carry them on
+	totals map[uint32]map[string]uint32
+	base   map[uint32]map[string]uint32
+}
+
+// chapter - a stream waiting to be read
+type chapter struct {
+	r     io.Reader
+	track *mp4.Track
+}
+
+// NewDecoder - decoder reading from r, buffered unless r already is. track
+// gives each payload its time in the video; without it (eg. for an extracted
+// .bin) payloads are taken to be a second each.
+func NewDecoder(r io.Reader, track *mp4.Track) *Decoder {
+	d := &Decoder{totals: map[uint32]map[string]uint32{}}
+	d.open(r, track)
+	return d
+}
+
+// open - start reading the stream of a chapter
+func (d *Decoder) open(r io.Reader, track *mp4.Track) {
+	if _, ok := r.(*bufio.Reader); !ok {
+		r = bufio.NewReaderSize(r, 64*1024)
+	}
+
+	lenient := d.r != nil && d.r.Lenient
+	d.r = gpmf.NewReader(r)
+	d.r.Lenient = lenient
+
+	d.track = track
+	d.starts = nil
+	if track != nil {
+		var off int64
+		for _, s := range track.Samples {
+			d.starts = append(d.starts, off)
+			off += s.Size
+		}
+	}
+
+	d.counts = map[uint32]int{}
+	d.base = map[uint32]map[string]uint32{}
+}
+
+// Append - read r after the current stream as the next chapter of the same
+// recording (see mp4.Chapters), eg. GH020001.MP4 after GH010001.MP4. Its
+// payloads carry on in time from the end of the previous chapter, and the
+// running sample counts in Totals carry on from where they got to.
+func (d *Decoder) Append(r io.Reader, track *mp4.Track) {
+	d.chapters = append(d.chapters, chapter{r, track})
+}
+
+// Lenient - skip corrupt data, and payloads that can't be decoded, instead
+// of failing. What was passed over is listed by Skipped.
+func (d *Decoder) Lenient() {
+	d.r.Lenient = true
+}
+
+// Select - only return payloads of the device with the given ID or name (see
+// DVID.Match), eg. the camera when a drone or sensor is also recording
+func (d *Decoder) Select(device string) {
+	d.device = device
+}
+
+// Skipped - the regions of the stream skipped so far in lenient mode. The
+// offsets are into the chapter each was in.
+func (d *Decoder) Skipped() []gpmf.Skip {
+	return append(append([]gpmf.Skip{}, d.skipped...), d.r.Skipped...)
+}
+
+// Next - the next DEVC payload, decoded and stamped with its media and UTC
+// times. It returns io.EOF once the stream, and any chapters appended to it,
+// are exhausted, and keeps returning the first error it hit.
+//
+// A stream cut short part way through a payload gives what was decoded of
+// it along with a gpmf.TruncatedError, which errors.Is matches to
+// gpmf.ErrTruncated, and then carries on with the next chapter, or returns
+// io.EOF if there is none.
+func (d *Decoder) Next() (*TELEM, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	for {
+		t, err := read(d.r)
+		for d.r.Lenient && t == nil && err != nil && err != io.EOF && !errors.Is(err, gpmf.ErrTruncated) {
+			d.r.SkipLast(err)
+			t, err = read(d.r)
+		}
+
+		if t != nil {
+			d.stamp(t)
+		}
+
+		ended := err == io.EOF || errors.Is(err, gpmf.ErrTruncated)
+		if ended && len(d.chapters) > 0 {
+			d.nextChapter()
+			if err == io.EOF {
+				continue
+			}
+		} else if errors.Is(err, gpmf.ErrTruncated) {
+			d.err = io.EOF
+		} else if err != nil {
+			d.err = err
+		}
+		if t == nil {
+			return nil, err
+		}
+
+		if d.device == "" || t.Device.Match(d.device) {
+			return t, err
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// stamp - time the payload just read and carry on its sample counts
+func (d *Decoder) stamp(t *TELEM) {
+	d.continueTotals(t)
+
+	// each device's payloads are timed on their own
+	start, duration := d.times(d.counts[t.Device.ID])
+	t.Stamp(d.offset+start, duration)
+	d.counts[t.Device.ID]++
+
+	if end := t.Start + t.Duration; end > d.end {
+		d.end = end
+	}
+}
+
+// nextChapter - move on to the next appended stream, starting it where the
+// current one ends: at the end of its track, or of its last payload when
+// that runs later or there is no track
+func (d *Decoder) nextChapter() {
+	if n := len(d.starts); n > 0 {
+		if end := d.offset + d.track.Time(n-1) + d.track.Duration(n-1); end > d.end {
+			d.end = end
+		}
+	}
+	d.offset = d.end
+	d.skipped = append(d.skipped, d.r.Skipped...)
+
+	c := d.chapters[0]
+	d.chapters = d.chapters[1:]
+	d.open(c.r, c.track)
+}
+
+// continueTotals - carry the running sample counts (TSMP) of t on from the
+// previous chapter. Cameras that count each chapter from zero get the count
+// the last one reached added; those that carry on counting are left alone.
+func (d *Decoder) continueTotals(t *TELEM) {
+	id := t.Device.ID
+	if d.totals[id] == nil {
+		d.totals[id] = map[string]uint32{}
+	}
+	if d.base[id] == nil {
+		d.base[id] = map[string]uint32{}
+	}
+	totals, base := d.totals[id], d.base[id]
+
+	for key, n := range t.Totals {
+		b, ok := base[key]
+		if !ok {
+			// first seen in this chapter: a count below where the last
+			// chapter got to has started again
+			if n < totals[key] {
+				b = totals[key]
+			}
+			base[key] = b
+		}
+
+		t.Totals[key] = n + b
+		totals[key] = t.Totals[key]
+	}
+}
+
+// times - start and length of the payload just read, the nth of its device:
+// those of the sample it came from, so skipped data doesn't throw out the
+// timing of what follows
+func (d *Decoder) times(n int) (time.Duration, time.Duration) {
+	if d.track == nil || len(d.starts) == 0 {
+		return time.Duration(n) * time.Second, time.Second
+	}
+
+	i := sort.Search(len(d.starts), func(i int) bool { return d.starts[i] > d.r.Last }) - 1
+	if i < 0 {
+		i = 0
+	}
+	return d.track.Time(i), d.track.Duration(i)
+}
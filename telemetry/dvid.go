@@ -0,0 +1,50 @@
+package telemetry
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+)
+
+// Device that recorded a payload, eg. the camera or a connected drone or
+// sensor, from DVID and DVNM
+type DVID struct {
+	ID   uint32 `json:"id"`
+	Name string `json:"name,omitempty"`
+
+	key string // the ID when it is given as a Four CC
+}
+
+func (dvid *DVID) Parse(bytes []byte, format byte) error {
+	if 4 != len(bytes) {
+		return &LengthError{FourCC: "DVID", Want: 4, Got: len(bytes)}
+	}
+
+	dvid.ID = binary.BigEndian.Uint32(bytes)
+	if 'F' == format || 'c' == format {
+		dvid.key = string(bytes)
+	}
+
+	return nil
+}
+
+// String - the name, or the ID for an unnamed device
+func (dvid DVID) String() string {
+	if dvid.Name != "" {
+		return dvid.Name
+	}
+	if dvid.key != "" {
+		return dvid.key
+	}
+	return strconv.FormatUint(uint64(dvid.ID), 10)
+}
+
+// Match - whether device is this device's number, Four CC or name (ignoring
+// case)
+func (dvid DVID) Match(device string) bool {
+	if id, err := strconv.ParseUint(device, 10, 32); err == nil && dvid.key == "" {
+		return uint32(id) == dvid.ID
+	}
+
+	return (dvid.key != "" && device == dvid.key) || strings.EqualFold(device, dvid.Name)
+}
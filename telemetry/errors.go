@@ -0,0 +1,36 @@
+package telemetry
+
+import (
+	"errors"
+
+	"github.com/stilldavid/gopro-utils/gpmf"
+)
+
+// ErrInvalidLength - a sample is not the size its stream needs. Errors
+// giving the sizes are LengthError.
+var ErrInvalidLength = gpmf.ErrInvalidLength
+
+// LengthError - a sample of the stream FourCC has the wrong size
+type LengthError = gpmf.LengthError
+
+// elementError - err from decoding the element n, as a *gpmf.Error saying
+// where it is. One that already is a *gpmf.Error is returned untouched.
+func elementError(n *gpmf.Node, err error) error {
+	var e *gpmf.Error
+	if errors.As(err, &e) {
+		return err
+	}
+
+	path := n.Path
+	if path == nil {
+		path = []string{n.Key()}
+	}
+	e = &gpmf.Error{Offset: n.Offset, Path: path, Err: err}
+
+	var l *LengthError
+	if errors.As(err, &l) {
+		e.Want, e.Got = l.Want, l.Got
+	}
+
+	return e
+}
@@ -0,0 +1,73 @@
+package telemetry
+
+import (
+	"errors"
+	"time"
+
+	"github.com/stilldavid/gopro-utils/gpmf"
+)
+
+// One detected face. The box is normalized to the frame, 0-1 from the top
+// left corner.
+type Face struct {
+	ID int `json:"id"`
+
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+
+	// Hero7 and later only
+	Confidence float64 `json:"confidence,omitempty"`
+	Smile      float64 `json:"smile,omitempty"`
+	Blink      float64 `json:"blink,omitempty"`
+}
+
+// Faces detected in a frame
+type FACE struct {
+	Faces []Face `json:"faces"`
+
+	MediaTime time.Duration `json:"media_time"` // since the start of the video
+	TS        int64         `json:"utc"`        // UTC in microseconds, zero without a GPS time
+}
+
+// Parse - decode one frame's FACE element using the stream's TYPE. The
+// structure differs between models:
+//
+//	5 fields:   ID, x, y, w, h (Hero6)
+//	9 fields:   version, confidence, ID, x, y, w, h, smile, blink (Hero8 and later)
+//	otherwise:  ID, x, y, w, h, unused..., smile (Hero7)
+func (face *FACE) Parse(n *gpmf.Node, typ gpmf.Type, scale *SCAL) error {
+	samples, err := n.ComplexFloats(typ)
+	if err != nil {
+		return err
+	}
+
+	for _, raw := range samples {
+		v, err := scale.Apply(raw...)
+		if err != nil {
+			return err
+		}
+
+		f := Face{}
+		switch {
+		case len(v) == 9:
+			f.Confidence = v[1]
+			f.ID = int(raw[2])
+			f.X, f.Y, f.W, f.H = v[3], v[4], v[5], v[6]
+			f.Smile, f.Blink = v[7], v[8]
+		case len(v) >= 5:
+			f.ID = int(raw[0])
+			f.X, f.Y, f.W, f.H = v[1], v[2], v[3], v[4]
+			if len(v) > 5 {
+				f.Smile = v[len(v)-1]
+			}
+		default:
+			return errors.New("Invalid FACE structure")
+		}
+
+		face.Faces = append(face.Faces, f)
+	}
+
+	return nil
+}
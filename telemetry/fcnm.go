@@ -0,0 +1,27 @@
+package telemetry
+
+import (
+	"time"
+)
+
+// Number of faces counted in a frame
+type FCNM struct {
+	Count int `json:"count"`
+
+	MediaTime time.Duration `json:"media_time"` // since the start of the video
+	TS        int64         `json:"utc"`        // UTC in microseconds, zero without a GPS time
+}
+
+func (fcnm *FCNM) Parse(bytes []byte, format byte, scale *SCAL) error {
+	v, err := numbers(format, bytes, scale)
+	if err != nil {
+		return err
+	}
+	if 1 != len(v) {
+		return &LengthError{FourCC: "FCNM", Want: formatSize(format), Got: len(bytes)}
+	}
+
+	fcnm.Count = int(v[0])
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package telemetry
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// GPS sentence from Hero11 and later: GPS5 plus its own UTC time, DOP and fix
+type GPS9 struct {
+	Latitude  float64 `json:"lat"`    // degrees lat
+	Longitude float64 `json:"lon"`    // degrees lon
+	Altitude  float64 `json:"alt"`    // meters above wgs84 ellipsoid
+	Speed     float64 `json:"spd"`    // m/s
+	Speed3D   float64 `json:"spd_3d"` // m/s
+	TS        int64   `json:"utc"`    // microseconds, from the sample itself
+	DOP       float64 `json:"dop"`    // dilution of precision
+	Fix       uint16  `json:"fix"`    // 0: none, 2: 2D, 3: 3D
+
+	MediaTime time.Duration `json:"media_time"` // since the start of the video
+}
+
+// GPS9 days are counted from here
+var gps9Epoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func (gps *GPS9) Parse(bytes []byte, scale *SCAL) error {
+	if 32 != len(bytes) {
+		return &LengthError{FourCC: "GPS9", Want: 32, Got: len(bytes)}
+	}
+
+	raw := make([]float64, 9)
+	for i := 0; i < 7; i++ {
+		raw[i] = float64(int32(binary.BigEndian.Uint32(bytes[4*i : 4*i+4])))
+	}
+	raw[7] = float64(binary.BigEndian.Uint16(bytes[28:30]))
+	raw[8] = float64(binary.BigEndian.Uint16(bytes[30:32]))
+
+	v, err := scale.Apply(raw...)
+	if err != nil {
+		return err
+	}
+
+	gps.Latitude = v[0]
+	gps.Longitude = v[1]
+	gps.Altitude = v[2]
+	gps.Speed = v[3]
+	gps.Speed3D = v[4]
+
+	// days since 2000 and seconds since midnight, to the millisecond
+	days := time.Duration(v[5]) * 24 * time.Hour
+	secs := time.Duration(math.Round(v[6]*1000)) * time.Millisecond
+	gps.TS = gps9Epoch.Add(days+secs).UnixNano() / 1000
+
+	gps.DOP = v[7]
+	gps.Fix = uint16(v[8])
+
+	return nil
+}
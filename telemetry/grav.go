@@ -0,0 +1,43 @@
+package telemetry
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// Gravity direction in the camera frame, unit length
+type GRAV struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+
+	MediaTime time.Duration `json:"media_time"` // since the start of the video
+	TS        int64         `json:"utc"`        // UTC in microseconds, zero without a GPS time
+}
+
+func (grav *GRAV) Parse(bytes []byte, scale *SCAL) error {
+	if 6 != len(bytes) {
+		return &LengthError{FourCC: "GRAV", Want: 6, Got: len(bytes)}
+	}
+
+	v, err := scale.Apply(
+		float64(int16(binary.BigEndian.Uint16(bytes[0:2]))),
+		float64(int16(binary.BigEndian.Uint16(bytes[2:4]))),
+		float64(int16(binary.BigEndian.Uint16(bytes[4:6]))),
+	)
+	if err != nil {
+		return err
+	}
+
+	grav.X, grav.Y, grav.Z = v[0], v[1], v[2]
+
+	return nil
+}
+
+// Tilt - roll and pitch of the camera in radians implied by the gravity vector
+func (grav *GRAV) Tilt() (roll, pitch float64) {
+	roll = math.Atan2(grav.Y, grav.Z)
+	pitch = math.Atan2(-grav.X, math.Sqrt(grav.Y*grav.Y+grav.Z*grav.Z))
+	return roll, pitch
+}
@@ -0,0 +1,48 @@
+package telemetry
+
+import (
+	"errors"
+	"time"
+
+	"github.com/stilldavid/gopro-utils/gpmf"
+)
+
+// One of the predominant hues of a frame
+type Hue struct {
+	Hue    float64 `json:"hue"`    // HSV hue in degrees
+	Weight float64 `json:"weight"` // 0 to 255
+}
+
+// Predominant hues of a frame
+type HUES struct {
+	Hues []Hue `json:"hues"`
+
+	MediaTime time.Duration `json:"media_time"` // since the start of the video
+	TS        int64         `json:"utc"`        // UTC in microseconds, zero without a GPS time
+}
+
+// Parse - decode one frame's HUES element, laid out by the stream's TYPE
+// (hue and weight bytes)
+func (hues *HUES) Parse(n *gpmf.Node, typ gpmf.Type) error {
+	samples, err := n.Complex(typ)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range samples {
+		if 2 != len(s) {
+			return errors.New("Invalid HUES structure")
+		}
+
+		hue, ok1 := s[0].(uint8)
+		weight, ok2 := s[1].(uint8)
+		if !ok1 || !ok2 {
+			return errors.New("Invalid HUES structure")
+		}
+
+		// hue is stored as 0-255 for 0-360°
+		hues.Hues = append(hues.Hues, Hue{float64(hue) * 360 / 255, float64(weight)})
+	}
+
+	return nil
+}
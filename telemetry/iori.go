@@ -0,0 +1,17 @@
+package telemetry
+
+import (
+	"time"
+)
+
+// Image orientation relative to the camera body, ie. what stabilization applied
+type IORI struct {
+	Quaternion
+
+	MediaTime time.Duration `json:"media_time"` // since the start of the video
+	TS        int64         `json:"utc"`        // UTC in microseconds, zero without a GPS time
+}
+
+func (iori *IORI) Parse(bytes []byte, scale *SCAL) error {
+	return iori.parse("IORI", bytes, scale)
+}
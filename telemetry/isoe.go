@@ -0,0 +1,27 @@
+package telemetry
+
+import (
+	"time"
+)
+
+// Sensor ISO of a frame
+type ISOE struct {
+	ISO float64 `json:"iso"`
+
+	MediaTime time.Duration `json:"media_time"` // since the start of the video
+	TS        int64         `json:"utc"`        // UTC in microseconds, zero without a GPS time
+}
+
+func (isoe *ISOE) Parse(bytes []byte, format byte, scale *SCAL) error {
+	v, err := numbers(format, bytes, scale)
+	if err != nil {
+		return err
+	}
+	if 1 != len(v) {
+		return &LengthError{FourCC: "ISOE", Want: formatSize(format), Got: len(bytes)}
+	}
+
+	isoe.ISO = v[0]
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package telemetry
+
+import (
+	"time"
+)
+
+// Image sensor gain of a frame (Hero5/6, replaced by ISOE)
+type ISOG struct {
+	Gain float64 `json:"gain"`
+
+	MediaTime time.Duration `json:"media_time"` // since the start of the video
+	TS        int64         `json:"utc"`        // UTC in microseconds, zero without a GPS time
+}
+
+func (isog *ISOG) Parse(bytes []byte, format byte, scale *SCAL) error {
+	v, err := numbers(format, bytes, scale)
+	if err != nil {
+		return err
+	}
+	if 1 != len(v) {
+		return &LengthError{FourCC: "ISOG", Want: formatSize(format), Got: len(bytes)}
+	}
+
+	isog.Gain = v[0]
+
+	return nil
+}
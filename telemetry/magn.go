@@ -0,0 +1,83 @@
+package telemetry
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// 3-axis Magnetometer data in µT
+type MAGN struct {
+	X float64
+	Y float64
+	Z float64
+
+	MediaTime time.Duration `json:"media_time"` // since the start of the video
+	TS        int64         `json:"utc"`        // UTC in microseconds, zero without a GPS time
+}
+
+func (magn *MAGN) Parse(bytes []byte, scale *SCAL) error {
+	if 6 != len(bytes) {
+		return &LengthError{FourCC: "MAGN", Want: 6, Got: len(bytes)}
+	}
+
+	v, err := scale.Apply(
+		float64(int16(binary.BigEndian.Uint16(bytes[0:2]))),
+		float64(int16(binary.BigEndian.Uint16(bytes[2:4]))),
+		float64(int16(binary.BigEndian.Uint16(bytes[4:6]))),
+	)
+	if err != nil {
+		return err
+	}
+
+	magn.X, magn.Y, magn.Z = v[0], v[1], v[2]
+
+	return nil
+}
+
+// Heading - tilt-compensated magnetic heading of the camera's X axis in
+// degrees clockwise from magnetic north (0-360), using the accelerometer
+// reading taken at the same moment to find which way is down. No declination
+// is applied.
+func (magn *MAGN) Heading(accl ACCL) float64 {
+	roll := math.Atan2(accl.Y, accl.Z)
+	pitch := math.Atan2(-accl.X, accl.Y*math.Sin(roll)+accl.Z*math.Cos(roll))
+
+	// rotate the field back into the horizontal plane
+	x := magn.X*math.Cos(pitch) + magn.Y*math.Sin(pitch)*math.Sin(roll) + magn.Z*math.Sin(pitch)*math.Cos(roll)
+	y := magn.Y*math.Cos(roll) - magn.Z*math.Sin(roll)
+
+	// into 0-360, which also turns a due north of -0 into 0
+	return math.Mod(math.Atan2(-y, x)*180/math.Pi+360, 360)
+}
+
+// HeadingAt - magnetic heading at media time mt from the nearest MAGN and
+// ACCL samples, false when the payload lacks either
+func (t *TELEM) HeadingAt(mt time.Duration) (float64, bool) {
+	if len(t.Magn) == 0 || len(t.Accl) == 0 {
+		return 0, false
+	}
+
+	m := t.Magn[nearest(len(t.Magn), func(i int) time.Duration { return t.Magn[i].MediaTime }, mt)]
+	a := t.Accl[nearest(len(t.Accl), func(i int) time.Duration { return t.Accl[i].MediaTime }, mt)]
+
+	return m.Heading(a), true
+}
+
+// nearest - index of the sample whose time is closest to mt
+func nearest(n int, at func(int) time.Duration, mt time.Duration) int {
+	best := 0
+	for i := 1; i < n; i++ {
+		if abs(at(i)-mt) < abs(at(best)-mt) {
+			best = i
+		}
+	}
+	return best
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
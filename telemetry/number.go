@@ -0,0 +1,29 @@
+package telemetry
+
+import (
+	"errors"
+
+	"github.com/stilldavid/gopro-utils/gpmf"
+)
+
+// numbers - every value of format f in a sample, scaled, for streams whose
+// format differs between camera models. The values are read by gpmf.
+func numbers(f byte, bytes []byte, scale *SCAL) ([]float64, error) {
+	n := gpmf.Node{KLV: gpmf.KLV{Format: f}, Data: bytes}
+	raw, err := n.Floats()
+	if err != nil {
+		// the sample's element is not known here, so only the cause is kept
+		var gerr *gpmf.Error
+		if errors.As(err, &gerr) {
+			return nil, gerr.Err
+		}
+		return nil, err
+	}
+
+	return scale.Apply(raw...)
+}
+
+// formatSize - bytes taken by one value of format f
+func formatSize(f byte) int {
+	return gpmf.Type{f}.Size()
+}
@@ -0,0 +1,104 @@
+package telemetry
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+)
+
+// Orientation - matrix taking the raw channels of a 3-axis sensor to camera
+// X/Y/Z, built from the stream's ORIN, ORIO and MTRX
+type Orientation [3][3]float64
+
+// the raw order is the camera order when a stream has no orientation keys
+var identity = Orientation{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+
+// NewOrientation - build the matrix for a stream.
+//
+// orin names the camera axis measured by each raw channel, lower case meaning
+// the channel is inverted: "YxZ" is +Y, -X, +Z. mtrx, when present, is the
+// 3x3 row-major calibration matrix taking raw channels to the axes named by
+// orio (default "XYZ"), and takes precedence over orin.
+func NewOrientation(orin, orio string, mtrx []float64) (Orientation, error) {
+	if mtrx != nil {
+		if len(mtrx) != 9 {
+			return identity, &LengthError{FourCC: "MTRX", Want: 36, Got: 4 * len(mtrx)}
+		}
+
+		if orio == "" {
+			orio = "XYZ"
+		}
+		p, err := axisMap(orio)
+		if err != nil {
+			return identity, err
+		}
+
+		// MTRX gives ORIO-ordered output, p puts it in X/Y/Z order
+		var o Orientation
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				for k := 0; k < 3; k++ {
+					o[i][j] += p[i][k] * mtrx[3*k+j]
+				}
+			}
+		}
+		return o, nil
+	}
+
+	if orin == "" {
+		return identity, nil
+	}
+
+	return axisMap(orin)
+}
+
+// axisMap - matrix placing channel i on the axis named by axes[i]
+func axisMap(axes string) (Orientation, error) {
+	axes = strings.TrimRight(axes, "\x00 ")
+	if len(axes) != 3 {
+		return identity, errors.New("Invalid orientation " + axes)
+	}
+
+	var o Orientation
+	seen := [3]bool{}
+	for i, c := range axes {
+		sign := 1.0
+		if c >= 'a' && c <= 'z' {
+			sign = -1
+			c -= 'a' - 'A'
+		}
+
+		axis := strings.IndexRune("XYZ", c)
+		if axis < 0 || seen[axis] {
+			return identity, errors.New("Invalid orientation " + axes)
+		}
+		seen[axis] = true
+
+		o[axis][i] = sign
+	}
+
+	return o, nil
+}
+
+// Apply - the camera X/Y/Z of raw channel values
+func (o *Orientation) Apply(a, b, c float64) (float64, float64, float64) {
+	return o[0][0]*a + o[0][1]*b + o[0][2]*c,
+		o[1][0]*a + o[1][1]*b + o[1][2]*c,
+		o[2][0]*a + o[2][1]*b + o[2][2]*c
+}
+
+// parseMTRX - the 32-bit floats of an MTRX payload
+func parseMTRX(bytes []byte) ([]float64, error) {
+	if 0 != len(bytes)%4 {
+		// the next whole number of floats
+		return nil, &LengthError{FourCC: "MTRX", Want: len(bytes) + 4 - len(bytes)%4, Got: len(bytes)}
+	}
+
+	values := make([]float64, len(bytes)/4)
+	for i := range values {
+		values[i] = float64(math.Float32frombits(binary.BigEndian.Uint32(bytes[4*i:])))
+	}
+
+	return values, nil
+}
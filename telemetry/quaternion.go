@@ -0,0 +1,48 @@
+package telemetry
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Unit quaternion describing a rotation
+type Quaternion struct {
+	W float64 `json:"w"`
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+// parse - the quaternion of one sample of the stream key
+func (q *Quaternion) parse(key string, bytes []byte, scale *SCAL) error {
+	if 8 != len(bytes) {
+		return &LengthError{FourCC: key, Want: 8, Got: len(bytes)}
+	}
+
+	v, err := scale.Apply(
+		float64(int16(binary.BigEndian.Uint16(bytes[0:2]))),
+		float64(int16(binary.BigEndian.Uint16(bytes[2:4]))),
+		float64(int16(binary.BigEndian.Uint16(bytes[4:6]))),
+		float64(int16(binary.BigEndian.Uint16(bytes[6:8]))),
+	)
+	if err != nil {
+		return err
+	}
+
+	q.W, q.X, q.Y, q.Z = v[0], v[1], v[2], v[3]
+
+	return nil
+}
+
+// Euler - roll, pitch and yaw in radians (rotation about X, then Y, then Z)
+func (q *Quaternion) Euler() (roll, pitch, yaw float64) {
+	roll = math.Atan2(2*(q.W*q.X+q.Y*q.Z), 1-2*(q.X*q.X+q.Y*q.Y))
+
+	// clamp for quaternions that aren't quite unit length
+	sinp := 2 * (q.W*q.Y - q.Z*q.X)
+	pitch = math.Asin(math.Max(-1, math.Min(1, sinp)))
+
+	yaw = math.Atan2(2*(q.W*q.Z+q.X*q.Y), 1-2*(q.Y*q.Y+q.Z*q.Z))
+
+	return roll, pitch, yaw
+}
@@ -1,207 +1,359 @@
 package telemetry
 
 import (
-	"fmt"
 	"io"
-	"io/ioutil"
+
+	"github.com/stilldavid/gopro-utils/gpmf"
 )
 
-func stringInSlice(a string, list []string) bool {
-	for _, b := range list {
-		if b == a {
-			return true
+// Read - decode the next DEVC payload from f, returning io.EOF when there is
+// no more telemetry. Elements this package doesn't know about are skipped.
+// The payload is not timed, see Decoder for that.
+//
+// When f ends part way through a payload, whatever its complete streams hold
+// is returned along with a gpmf.TruncatedError, so a clip cut short by a dead
+// battery still yields its telemetry.
+func Read(f io.Reader) (*TELEM, error) {
+	return read(gpmf.NewReader(f))
+}
+
+func read(r *gpmf.Reader) (*TELEM, error) {
+	for {
+		devc, err := r.Next()
+		if devc == nil {
+			return nil, err
 		}
+
+		// anything at the top level other than a device is ignored
+		if "DEVC" != devc.Key() {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		t, derr := readDevice(devc)
+		if derr != nil {
+			return nil, derr
+		}
+
+		return t, err
 	}
-	return false
 }
 
-func Read(f io.Reader) (*TELEM, error) {
-	labels := []string{
-		"ACCL",
-		"DEVC",
-		"DVID",
-		"DVNM",
-		"EMPT",
-		"GPRO",
-		"GPS5",
-		"GPSF",
-		"GPSP",
-		"GPSU",
-		"GYRO",
-		"HD5.",
-		"ISOG",
-		"SCAL",
-		"SHUT",
-		"SIUN",
-		"STNM",
-		"STRM",
-		"TICK",
-		"TMPC",
-		"TSMP",
-		"UNIT",
-		"TYPE",
-		"FACE",
-		"FCNM",
-		"ISOE",
-		"WBAL",
-		"WRGB",
-		"MAGN",
-		"ALLD",
-		"MTRX",
-		"ORIN",
-		"ORIO",
-		"YAVG",
-		"UNIF",
-		"SCEN",
-		"HUES",
-		"SROT",
-		"TIMO",
+// readDevice - decode the streams of one DEVC container
+func readDevice(devc *gpmf.Node) (*TELEM, error) {
+	t := &TELEM{
+		Totals:  map[string]uint32{},
+		Complex: map[string]COMPLEX{},
+		Streams: map[string]StreamInfo{},
+	}
+	for _, n := range devc.Children {
+		switch n.Key() {
+		case "DVID":
+			if err := t.Device.Parse(n.Data, n.Format); err != nil {
+				return nil, elementError(n, err)
+			}
+		case "DVNM":
+			t.Device.Name = text(n.Data)
+		case "STRM":
+			if err := t.readStream(n); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	label := make([]byte, 4, 4) // 4 byte ascii label of data
-	desc := make([]byte, 4, 4)  // 4 byte description of length of data
+	return t, nil
+}
+
+// readStream - decode the values of one STRM container into t
+func (t *TELEM) readStream(strm *gpmf.Node) (err error) {
+	// the element being decoded, to say where any error is
+	var n *gpmf.Node
+	defer func() {
+		if err != nil && n != nil {
+			err = elementError(n, err)
+		}
+	}()
 
 	// keep a copy of the scale to apply to subsequent sentences
 	s := SCAL{}
 
-	// the full telemetry for this period
-	t := &TELEM{}
+	// running sample count, recorded against the stream's data
+	tsmp := TSMP{}
 
-	for {
-		// pick out the label
-		read, err := f.Read(label)
-		if err == io.EOF || read == 0 {
-			return nil, err
-		}
+	// layout of complex structures in this stream
+	var typ gpmf.Type
 
-		label_string := string(label)
+	// axis mapping of 3-axis sensors, built when their data arrives
+	var orin, orio string
+	var mtrx []float64
+	var o *Orientation
 
-		if !stringInSlice(label_string, labels) {
-			err := fmt.Errorf("Could not find label in list: %s (%x)\n", label, label)
-			return nil, err
-		}
+	// name and units, for each of the stream's data keys
+	info := StreamInfo{}
+	var keys []string
+
+	for _, n = range strm.Children {
+		label_string := n.Key()
+
+		// uncomment to see label, type, size and count
+		//fmt.Printf("%s (%c) of size %v and count %v\n", label_string, n.Format, n.Size, n.Count)
 
-		// pick out the label description
-		read, err = f.Read(desc)
-		if err == io.EOF || read == 0 {
-			break
+		if info.parse(n) {
+			continue
+		}
+		if !streamMetadata[label_string] {
+			keys = append(keys, label_string)
 		}
 
-		// first byte is zero, there is no length
-		if 0x0 == desc[0] {
+		if "SCAL" == label_string {
+			err := s.Parse(n.Data, int64(n.Size))
+			if err != nil {
+				return err
+			}
 			continue
 		}
 
-		// skip empty packets
-		if "EMPT" == label_string {
-			io.CopyN(ioutil.Discard, f, 4)
+		if "ORIN" == label_string || "ORIO" == label_string || "MTRX" == label_string {
+			if "ORIN" == label_string {
+				orin = string(n.Data)
+			} else if "ORIO" == label_string {
+				orio = string(n.Data)
+			} else {
+				var err error
+				mtrx, err = parseMTRX(n.Data)
+				if err != nil {
+					return err
+				}
+			}
+			o = nil
 			continue
 		}
 
-		// extract the size and length
-		val_size := int64(desc[1])
-		num_values := (int64(desc[2]) << 8) | int64(desc[3])
-		length := val_size * num_values
+		if ("ACCL" == label_string || "GYRO" == label_string || "MAGN" == label_string) && o == nil {
+			orientation, err := NewOrientation(orin, orio, mtrx)
+			if err != nil {
+				return err
+			}
+			o = &orientation
+		}
+
+		if "TYPE" == label_string {
+			var err error
+			typ, err = gpmf.ParseType(string(n.Data))
+			if err != nil {
+				return err
+			}
+			continue
+		}
 
-		// uncomment to see label, type, size and length
-		//fmt.Printf("%s (%c) of size %v and len %v\n", label, desc[0], val_size, length)
+		if "GPS9" == label_string {
+			for _, value := range n.Samples() {
+				g := GPS9{}
+				err := g.Parse(value, &s)
+				if err != nil {
+					return err
+				}
+				t.Gps9 = append(t.Gps9, g)
+			}
+			t.Totals[label_string] = tsmp.Samples
+			continue
+		}
 
-		if "SCAL" == label_string {
-			value := make([]byte, val_size*num_values, val_size*num_values)
-			read, err = f.Read(value)
-			if err == io.EOF || read == 0 {
-				return nil, err
+		if ("HUES" == label_string || "SCEN" == label_string || "FACE" == label_string) && typ != nil {
+			if "FACE" == label_string {
+				f := FACE{}
+				err := f.Parse(n, typ, &s)
+				if err != nil {
+					return err
+				}
+				t.Face = append(t.Face, f)
+			} else if "HUES" == label_string {
+				h := HUES{}
+				err := h.Parse(n, typ)
+				if err != nil {
+					return err
+				}
+				t.Hues = append(t.Hues, h)
+			} else {
+				sc := SCEN{}
+				err := sc.Parse(n, typ)
+				if err != nil {
+					return err
+				}
+				t.Scen = append(t.Scen, sc)
 			}
+			t.Totals[label_string] = tsmp.Samples
+			continue
+		}
 
-			// clear the scales
-			s.Values = s.Values[:0]
+		// structures laid out by TYPE
+		if '?' == n.Format {
+			if typ == nil {
+				continue
+			}
 
-			err := s.Parse(value, val_size)
+			c := t.Complex[label_string]
+			err := c.Parse(n, typ)
 			if err != nil {
-				return nil, err
+				return err
 			}
-		} else {
-			value := make([]byte, val_size)
-
-			for i := int64(0); i < num_values; i++ {
-				read, err := f.Read(value)
-				if err == io.EOF || read == 0 {
-					return nil, err
-				}
-
-				// I think DVID is the payload boundary; this might be a bad assumption
-				if "DVID" == label_string {
-
-					// XXX: I think this might skip the first sentence
-					return t, nil
-				} else if "GPS5" == label_string {
-					g := GPS5{}
-					g.Parse(value, &s)
-					t.Gps = append(t.Gps, g)
-				} else if "GPSU" == label_string {
-					g := GPSU{}
-					err := g.Parse(value)
-					if err != nil {
-						return nil, err
-					}
-					t.Time = g
-				} else if "ACCL" == label_string {
-					a := ACCL{}
-					err := a.Parse(value, &s)
-					if err != nil {
-						return nil, err
-					}
-					t.Accl = append(t.Accl, a)
-				} else if "TMPC" == label_string {
-					tmp := TMPC{}
-					tmp.Parse(value)
-					t.Temp = tmp
-				} else if "TSMP" == label_string {
-					tsmp := TSMP{}
-					tsmp.Parse(value, &s)
-				} else if "GYRO" == label_string {
-					g := GYRO{}
-					err := g.Parse(value, &s)
-					if err != nil {
-						return nil, err
-					}
-					t.Gyro = append(t.Gyro, g)
-				} else if "GPSP" == label_string {
-					g := GPSP{}
-					err := g.Parse(value)
-					if err != nil {
-						return nil, err
-					}
-					t.GpsAccuracy = g
-				} else if "GPSF" == label_string {
-					g := GPSF{}
-					err := g.Parse(value)
-					if err != nil {
-						return nil, err
-					}
-					t.GpsFix = g
-				} else if "UNIT" == label_string {
-					// this is a string of units like "rad/s", not sure if it changes
-					//fmt.Printf("\tvals: %s\n", value)
-				} else if "SIUN" == label_string {
-					// this is the SI unit - also not sure if it changes
-					//fmt.Printf("\tvals: %s\n", value)
-				} else if "DVNM" == label_string {
-					// device name, "Camera"
-					//fmt.Printf("\tvals: %s\n", value)
-				} else {
-					//fmt.Printf("\tvalue is %v\n", value)
+			t.Complex[label_string] = c
+			continue
+		}
+
+		for _, value := range n.Samples() {
+			if "GPS5" == label_string {
+				g := GPS5{}
+				err := g.Parse(value, &s)
+				if err != nil {
+					return err
 				}
+				t.Gps = append(t.Gps, g)
+			} else if "GPSU" == label_string {
+				g := GPSU{}
+				err := g.Parse(value)
+				if err != nil {
+					return err
+				}
+				t.Time = g
+			} else if "ACCL" == label_string {
+				a := ACCL{}
+				err := a.Parse(value, &s)
+				if err != nil {
+					return err
+				}
+				a.X, a.Y, a.Z = o.Apply(a.X, a.Y, a.Z)
+				t.Accl = append(t.Accl, a)
+			} else if "TMPC" == label_string {
+				tmp := TMPC{}
+				tmp.Parse(value)
+				t.Temp = tmp
+			} else if "TSMP" == label_string {
+				tsmp.Parse(value)
+			} else if "GYRO" == label_string {
+				g := GYRO{}
+				err := g.Parse(value, &s)
+				if err != nil {
+					return err
+				}
+				g.X, g.Y, g.Z = o.Apply(g.X, g.Y, g.Z)
+				t.Gyro = append(t.Gyro, g)
+			} else if "MAGN" == label_string {
+				m := MAGN{}
+				err := m.Parse(value, &s)
+				if err != nil {
+					return err
+				}
+				m.X, m.Y, m.Z = o.Apply(m.X, m.Y, m.Z)
+				t.Magn = append(t.Magn, m)
+			} else if "CORI" == label_string {
+				c := CORI{}
+				err := c.Parse(value, &s)
+				if err != nil {
+					return err
+				}
+				t.Cori = append(t.Cori, c)
+			} else if "IORI" == label_string {
+				i := IORI{}
+				err := i.Parse(value, &s)
+				if err != nil {
+					return err
+				}
+				t.Iori = append(t.Iori, i)
+			} else if "GRAV" == label_string {
+				g := GRAV{}
+				err := g.Parse(value, &s)
+				if err != nil {
+					return err
+				}
+				t.Grav = append(t.Grav, g)
+			} else if "SHUT" == label_string {
+				sh := SHUT{}
+				err := sh.Parse(value, n.Format, &s)
+				if err != nil {
+					return err
+				}
+				t.Shut = append(t.Shut, sh)
+			} else if "ISOG" == label_string {
+				i := ISOG{}
+				err := i.Parse(value, n.Format, &s)
+				if err != nil {
+					return err
+				}
+				t.Isog = append(t.Isog, i)
+			} else if "ISOE" == label_string {
+				i := ISOE{}
+				err := i.Parse(value, n.Format, &s)
+				if err != nil {
+					return err
+				}
+				t.Isoe = append(t.Isoe, i)
+			} else if "WBAL" == label_string {
+				w := WBAL{}
+				err := w.Parse(value, n.Format, &s)
+				if err != nil {
+					return err
+				}
+				t.Wbal = append(t.Wbal, w)
+			} else if "WRGB" == label_string {
+				w := WRGB{}
+				err := w.Parse(value, n.Format, &s)
+				if err != nil {
+					return err
+				}
+				t.Wrgb = append(t.Wrgb, w)
+			} else if "YAVG" == label_string {
+				y := YAVG{}
+				err := y.Parse(value, n.Format, &s)
+				if err != nil {
+					return err
+				}
+				t.Yavg = append(t.Yavg, y)
+			} else if "UNIF" == label_string {
+				u := UNIF{}
+				err := u.Parse(value, n.Format, &s)
+				if err != nil {
+					return err
+				}
+				t.Unif = append(t.Unif, u)
+			} else if "FCNM" == label_string {
+				f := FCNM{}
+				err := f.Parse(value, n.Format, &s)
+				if err != nil {
+					return err
+				}
+				t.Fcnm = append(t.Fcnm, f)
+			} else if "GPSP" == label_string {
+				g := GPSP{}
+				err := g.Parse(value)
+				if err != nil {
+					return err
+				}
+				t.GpsAccuracy = g
+			} else if "GPSF" == label_string {
+				g := GPSF{}
+				err := g.Parse(value)
+				if err != nil {
+					return err
+				}
+				t.GpsFix = g
 			}
 		}
 
-		// pack into 4 bytes
-		mod := length % 4
-		if mod != 0 {
-			seek := 4 - mod
-			io.CopyN(ioutil.Discard, f, seek)
+		switch label_string {
+		case "ACCL", "GYRO", "MAGN", "GPS5", "CORI", "IORI", "GRAV",
+			"SHUT", "ISOG", "ISOE", "WBAL", "WRGB", "YAVG", "UNIF", "FCNM":
+			t.Totals[label_string] = tsmp.Samples
+		}
+	}
+
+	if !info.IsZero() {
+		for _, key := range keys {
+			t.Streams[key] = info
 		}
 	}
 
-	return nil, nil
+	return nil
 }
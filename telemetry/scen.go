@@ -0,0 +1,48 @@
+package telemetry
+
+import (
+	"errors"
+	"time"
+
+	"github.com/stilldavid/gopro-utils/gpmf"
+)
+
+// Likelihood of one scene class, eg. "SNOW", "URBA", "INDO", "WATR", "VEGE"
+// or "BEAC"
+type Scene struct {
+	Class       string  `json:"class"`
+	Probability float64 `json:"probability"`
+}
+
+// Scene classification of a frame
+type SCEN struct {
+	Scenes []Scene `json:"scenes"`
+
+	MediaTime time.Duration `json:"media_time"` // since the start of the video
+	TS        int64         `json:"utc"`        // UTC in microseconds, zero without a GPS time
+}
+
+// Parse - decode one frame's SCEN element, laid out by the stream's TYPE
+// (FourCC class and float probability)
+func (scen *SCEN) Parse(n *gpmf.Node, typ gpmf.Type) error {
+	samples, err := n.Complex(typ)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range samples {
+		if 2 != len(s) {
+			return errors.New("Invalid SCEN structure")
+		}
+
+		class, ok1 := s[0].(string)
+		prob, ok2 := s[1].(float32)
+		if !ok1 || !ok2 {
+			return errors.New("Invalid SCEN structure")
+		}
+
+		scen.Scenes = append(scen.Scenes, Scene{class, float64(prob)})
+	}
+
+	return nil
+}
@@ -0,0 +1,19 @@
+package telemetry
+
+import (
+	"github.com/stilldavid/gopro-utils/gpmf"
+)
+
+// The sensor types common to every camera are the gpmf package's, which
+// parses them
+type (
+	SCAL = gpmf.SCAL // divisors for the samples that follow
+	ACCL = gpmf.ACCL // accelerometer in m/s²
+	GYRO = gpmf.GYRO // gyroscope in rad/s
+	GPS5 = gpmf.GPS5 // GPS position and speed
+	GPSF = gpmf.GPSF // GPS fix
+	GPSP = gpmf.GPSP // GPS precision
+	GPSU = gpmf.GPSU // GPS UTC time
+	TMPC = gpmf.TMPC // temperature in °C
+	TSMP = gpmf.TSMP // total samples delivered
+)
@@ -0,0 +1,27 @@
+package telemetry
+
+import (
+	"time"
+)
+
+// Exposure (shutter) time of a frame in seconds
+type SHUT struct {
+	Exposure float64 `json:"exposure"`
+
+	MediaTime time.Duration `json:"media_time"` // since the start of the video
+	TS        int64         `json:"utc"`        // UTC in microseconds, zero without a GPS time
+}
+
+func (shut *SHUT) Parse(bytes []byte, format byte, scale *SCAL) error {
+	v, err := numbers(format, bytes, scale)
+	if err != nil {
+		return err
+	}
+	if 1 != len(v) {
+		return &LengthError{FourCC: "SHUT", Want: formatSize(format), Got: len(bytes)}
+	}
+
+	shut.Exposure = v[0]
+
+	return nil
+}
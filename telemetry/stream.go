@@ -0,0 +1,68 @@
+package telemetry
+
+import (
+	"strings"
+
+	"github.com/stilldavid/gopro-utils/gpmf"
+)
+
+// What a stream holds, from its STNM, SIUN, UNIT and RMRK
+type StreamInfo struct {
+	Name    string   `json:"name,omitempty"`     // eg. "Accelerometer"
+	SIUnits []string `json:"si_units,omitempty"` // one per value, or one for all, eg. "m/s²"
+	Units   []string `json:"units,omitempty"`    // for display, same layout as SIUnits
+	Remarks string   `json:"remarks,omitempty"`
+}
+
+// keys in a stream that describe or qualify its data rather than being it
+var streamMetadata = map[string]bool{
+	"STNM": true, "SIUN": true, "UNIT": true, "RMRK": true,
+	"SCAL": true, "TSMP": true, "TYPE": true, "ORIN": true, "ORIO": true, "MTRX": true,
+	"TMPC": true, "TICK": true, "TOCK": true, "EMPT": true, "STMP": true, "TIMO": true,
+	"GPSU": true, "GPSF": true, "GPSP": true,
+}
+
+// parse - take n into info if it is one of the descriptive keys, reporting
+// whether it was
+func (info *StreamInfo) parse(n *gpmf.Node) bool {
+	switch n.Key() {
+	case "STNM":
+		info.Name = text(n.Data)
+	case "RMRK":
+		info.Remarks = text(n.Data)
+	case "SIUN":
+		info.SIUnits = texts(n)
+	case "UNIT":
+		info.Units = texts(n)
+	default:
+		return false
+	}
+	return true
+}
+
+// IsZero - whether the stream had no description
+func (info *StreamInfo) IsZero() bool {
+	return info.Name == "" && info.Remarks == "" && len(info.SIUnits) == 0 && len(info.Units) == 0
+}
+
+// texts - one string per sample of a 'c' element
+func texts(n *gpmf.Node) []string {
+	var ss []string
+	for _, b := range n.Samples() {
+		ss = append(ss, text(b))
+	}
+	return ss
+}
+
+// text - a zero padded GPMF string, whose bytes are Latin-1 (eg. 0xB2 for
+// the ² of m/s²)
+func text(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		if c == 0 {
+			break
+		}
+		sb.WriteRune(rune(c))
+	}
+	return strings.TrimSpace(sb.String())
+}
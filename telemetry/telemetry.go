@@ -2,19 +2,47 @@ package telemetry
 
 import (
 	"time"
-
-	"github.com/paulmach/go.geo"
 )
 
 // Represents one second of telemetry data
 type TELEM struct {
+	Device      DVID
 	Accl        []ACCL
 	Gps         []GPS5
+	Gps9        []GPS9
 	Gyro        []GYRO
+	Magn        []MAGN
+	Cori        []CORI
+	Iori        []IORI
+	Grav        []GRAV
+	Shut        []SHUT
+	Isog        []ISOG
+	Isoe        []ISOE
+	Wbal        []WBAL
+	Wrgb        []WRGB
+	Yavg        []YAVG
+	Unif        []UNIF
+	Hues        []HUES
+	Scen        []SCEN
+	Face        []FACE
+	Fcnm        []FCNM
 	GpsFix      GPSF
 	GpsAccuracy GPSP
 	Time        GPSU
 	Temp        TMPC
+
+	// running sample count (TSMP) of each stream, keyed by FourCC
+	Totals map[string]uint32
+
+	// streams described by a TYPE, keyed by FourCC
+	Complex map[string]COMPLEX
+
+	// name and units of each stream that has them, keyed by FourCC
+	Streams map[string]StreamInfo
+
+	// where the payload sits in the video, see Stamp
+	Start    time.Duration
+	Duration time.Duration
 }
 
 // the thing we want, json-wise
@@ -22,30 +50,51 @@ type TELEM struct {
 type TELEM_OUT struct {
 	*GPS5
 
-	GpsAccuracy uint16  `json:"gps_accuracy,omitempty"`
-	GpsFix      uint32  `json:"gps_fix,omitempty"`
-	Temp        float32 `json:"temp,omitempty"`
-	Track       float64 `json:"track,omitempty"`
+	GpsAccuracy uint16   `json:"gps_accuracy,omitempty"`
+	GpsFix      uint32   `json:"gps_fix,omitempty"`
+	Temp        float32  `json:"temp,omitempty"`
+	Track       float64  `json:"track,omitempty"`
+	Heading     *float64 `json:"heading,omitempty"` // magnetic, when there is a MAGN stream
 }
 
-var pp *geo.Point = geo.NewPoint(10, 10)
-var last_good_track float64 = 0
-
 // zeroes out the telem struct
 func (t *TELEM) Clear() {
 	t.Accl = t.Accl[:0]
 	t.Gps = t.Gps[:0]
+	t.Gps9 = t.Gps9[:0]
 	t.Gyro = t.Gyro[:0]
+	t.Magn = t.Magn[:0]
+	t.Cori = t.Cori[:0]
+	t.Iori = t.Iori[:0]
+	t.Grav = t.Grav[:0]
+	t.Shut = t.Shut[:0]
+	t.Isog = t.Isog[:0]
+	t.Isoe = t.Isoe[:0]
+	t.Wbal = t.Wbal[:0]
+	t.Wrgb = t.Wrgb[:0]
+	t.Yavg = t.Yavg[:0]
+	t.Unif = t.Unif[:0]
+	t.Hues = t.Hues[:0]
+	t.Scen = t.Scen[:0]
+	t.Face = t.Face[:0]
+	t.Fcnm = t.Fcnm[:0]
 	t.Time.Time = time.Time{}
+	t.Device = DVID{}
+	t.Totals = map[string]uint32{}
+	t.Complex = map[string]COMPLEX{}
+	t.Streams = map[string]StreamInfo{}
+	t.Start, t.Duration = 0, 0
 }
 
 // determines if the telem has data
 func (t *TELEM) IsZero() bool {
-	// hack.
-	return t.Time.Time.IsZero()
+	// hack. GPS9 carries its own time, later cameras may have no GPSU
+	return t.Time.Time.IsZero() && len(t.Gps9) == 0
 }
 
 // try to populate a timestamp for every GPS row. probably bogus.
+//
+// Deprecated: use Stamp, which times every sample from TSMP and the video.
 func (t *TELEM) FillTimes(until time.Time) error {
 	len := len(t.Gps)
 	diff := until.Sub(t.Time.Time)
@@ -61,35 +110,10 @@ func (t *TELEM) FillTimes(until time.Time) error {
 	return nil
 }
 
+// ShitJson - the GPS rows of this payload on their own.
+//
+// Deprecated: rows are tracked without the payloads before them, so the
+// first row's track only comes from the ones after it. Use a Tracker per file.
 func (t *TELEM) ShitJson() []TELEM_OUT {
-	var out []TELEM_OUT
-
-	for i, _ := range t.Gps {
-		jobj := TELEM_OUT{&t.Gps[i], 0, 0, 0, 0}
-		if 0 == i {
-			jobj.GpsAccuracy = t.GpsAccuracy.Accuracy
-			jobj.GpsFix = t.GpsFix.F
-			jobj.Temp = t.Temp.Temp
-		}
-
-		p := geo.NewPoint(jobj.GPS5.Longitude, jobj.GPS5.Latitude)
-		jobj.Track = pp.BearingTo(p)
-		pp = p
-
-		if jobj.Track < 0 {
-			jobj.Track = 360 + jobj.Track
-		}
-
-		// only set the track if speed is over 1 m/s
-		// if it's slower (eg, stopped) it will drift all over with the location
-		if jobj.GPS5.Speed > 1 {
-			last_good_track = jobj.Track
-		} else {
-			jobj.Track = last_good_track
-		}
-
-		out = append(out, jobj)
-	}
-
-	return out
+	return NewTracker().Rows(t)
 }
@@ -0,0 +1,550 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stilldavid/gopro-utils/gpmf"
+	"github.com/stilldavid/gopro-utils/mp4"
+)
+
+func TestStamp(t *testing.T) {
+	gpsu := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	telem := &TELEM{
+		Accl:   make([]ACCL, 4),
+		Gyro:   make([]GYRO, 2),
+		Time:   GPSU{Time: gpsu},
+		Totals: map[string]uint32{"ACCL": 8},
+	}
+
+	// second payload of a 4 Hz stream
+	telem.Stamp(1*time.Second, time.Second)
+
+	// TSMP says 8 samples over 2 seconds, this payload holds 4 to 7
+	want := []time.Duration{1000, 1250, 1500, 1750}
+	for i, a := range telem.Accl {
+		if a.MediaTime != want[i]*time.Millisecond {
+			t.Errorf("ACCL %d at %v, want %vms", i, a.MediaTime, want[i])
+		}
+	}
+
+	// no TSMP: spread over the payload
+	if telem.Gyro[1].MediaTime != 1500*time.Millisecond {
+		t.Errorf("GYRO 1 at %v, want 1.5s", telem.Gyro[1].MediaTime)
+	}
+
+	if telem.Accl[2].TS != gpsu.Add(500*time.Millisecond).UnixNano()/1000 {
+		t.Errorf("ACCL 2 has UTC %d", telem.Accl[2].TS)
+	}
+
+	if b, _ := json.Marshal(telem.Accl[2]); !strings.Contains(string(b), `"media_time":1500000000`) || !strings.Contains(string(b), `"utc"`) {
+		t.Errorf("ACCL marshalled as %s", b)
+	}
+
+	// reused for the next payload, nothing of this one carries over
+	telem.Clear()
+	if len(telem.Totals) != 0 || telem.Start != 0 || telem.Duration != 0 {
+		t.Errorf("cleared to totals %v, start %v, duration %v", telem.Totals, telem.Start, telem.Duration)
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	// channel 0 is +Y, channel 1 is -X, channel 2 is +Z
+	o, err := NewOrientation("YxZ", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x, y, z := o.Apply(1, 2, 3); x != -2 || y != 1 || z != 3 {
+		t.Errorf("ORIN YxZ gave %v, %v, %v", x, y, z)
+	}
+
+	// MTRX swapping the first two channels, output in Z/X/Y order
+	mtrx := []float64{0, 1, 0, 1, 0, 0, 0, 0, 1}
+	o, err = NewOrientation("YxZ", "ZXY", mtrx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x, y, z := o.Apply(1, 2, 3); x != 1 || y != 3 || z != 2 {
+		t.Errorf("MTRX gave %v, %v, %v", x, y, z)
+	}
+
+	if _, err := NewOrientation("XXZ", "", nil); err == nil {
+		t.Error("expected an error for a repeated axis")
+	}
+}
+
+func TestScale(t *testing.T) {
+	one := SCAL{Values: []int{10}}
+	if v, err := one.Apply(10, 20, 30); err != nil || v[0] != 1 || v[1] != 2 || v[2] != 3 {
+		t.Errorf("single divisor gave %v, %v", v, err)
+	}
+
+	each := SCAL{Values: []int{1, 2, 4}}
+	if v, err := each.Apply(4, 4, 4); err != nil || v[0] != 4 || v[1] != 2 || v[2] != 1 {
+		t.Errorf("per-axis divisors gave %v, %v", v, err)
+	}
+
+	if _, err := each.Apply(1, 2, 3, 4, 5); err == nil {
+		t.Error("expected an error for mismatched SCAL")
+	}
+
+	// short SCAL used to panic
+	g := GPS5{}
+	if err := g.Parse(make([]byte, 20), &SCAL{Values: []int{1, 2}}); err == nil {
+		t.Error("expected an error parsing GPS5 with two divisors")
+	}
+}
+
+func TestGPS9(t *testing.T) {
+	sample := []byte{
+		0x1D, 0xCD, 0x65, 0x00, // lat 500000000
+		0xF8, 0x6E, 0x22, 0x40, // lon -127000000
+		0x00, 0x00, 0x30, 0x39, // alt 12345
+		0x00, 0x00, 0x03, 0xE8, // 2D speed 1000
+		0x00, 0x00, 0x07, 0xD0, // 3D speed 2000
+		0x00, 0x00, 0x21, 0xF5, // day 8693
+		0x02, 0x93, 0x8A, 0x7B, // 43223675 ms
+		0x00, 0x96, // DOP 150
+		0x00, 0x03, // 3D fix
+	}
+	scale := SCAL{Values: []int{10000000, 10000000, 1000, 1000, 100, 1, 1000, 100, 1}}
+
+	g := GPS9{}
+	if err := g.Parse(sample, &scale); err != nil {
+		t.Fatal(err)
+	}
+
+	if g.Latitude != 50 || g.Longitude != -12.7 || g.Altitude != 12.345 || g.Speed != 1 || g.Speed3D != 20 {
+		t.Errorf("bad position %+v", g)
+	}
+	if g.DOP != 1.5 || g.Fix != 3 {
+		t.Errorf("bad DOP/fix %v/%v", g.DOP, g.Fix)
+	}
+
+	utc := time.Date(2023, 10, 20, 12, 0, 23, 675000000, time.UTC)
+	if g.TS != utc.UnixNano()/1000 {
+		t.Errorf("got UTC %v, want %v", time.Unix(0, g.TS*1000).UTC(), utc)
+	}
+}
+
+func TestHeading(t *testing.T) {
+	level := ACCL{Z: 9.81}
+
+	// field pointing along X when level: facing magnetic north
+	north := MAGN{X: 20, Z: -40}
+	if h := north.Heading(level); math.Abs(h) > 1e-9 {
+		t.Errorf("facing north gave %v", h)
+	}
+
+	// field along -Y: facing east
+	east := MAGN{Y: -20, Z: -40}
+	if h := east.Heading(level); math.Abs(h-90) > 1e-9 {
+		t.Errorf("facing east gave %v", h)
+	}
+
+	telem := &TELEM{Accl: []ACCL{level}, Magn: []MAGN{east}}
+	if h, ok := telem.HeadingAt(0); !ok || math.Abs(h-90) > 1e-9 {
+		t.Errorf("HeadingAt gave %v, %v", h, ok)
+	}
+
+	// due north is a heading, not a missing one
+	telem = &TELEM{Gps: []GPS5{{}}, Accl: []ACCL{level}, Magn: []MAGN{north}}
+	b, _ := json.Marshal(NewTracker().Rows(telem)[0])
+	if !strings.Contains(string(b), `"heading":0`) {
+		t.Errorf("facing north output as %s", b)
+	}
+}
+
+func TestEuler(t *testing.T) {
+	// 90 degrees about Z
+	q := Quaternion{W: math.Sqrt2 / 2, Z: math.Sqrt2 / 2}
+
+	roll, pitch, yaw := q.Euler()
+	if math.Abs(roll) > 1e-9 || math.Abs(pitch) > 1e-9 || math.Abs(yaw-math.Pi/2) > 1e-9 {
+		t.Errorf("got roll %v, pitch %v, yaw %v", roll, pitch, yaw)
+	}
+}
+
+func TestImageStreams(t *testing.T) {
+	shut := SHUT{}
+	// 1/128 s as a 32-bit float
+	if err := shut.Parse([]byte{0x3C, 0x00, 0x00, 0x00}, 'f', &SCAL{}); err != nil || shut.Exposure != 1.0/128 {
+		t.Errorf("SHUT gave %v, %v", shut.Exposure, err)
+	}
+
+	wbal := WBAL{}
+	if err := wbal.Parse([]byte{0x15, 0x7C}, 'S', &SCAL{}); err != nil || wbal.Kelvin != 5500 {
+		t.Errorf("WBAL gave %v, %v", wbal.Kelvin, err)
+	}
+
+	yavg := YAVG{}
+	if err := yavg.Parse([]byte{0x01, 0x02}, 'B', &SCAL{}); err == nil {
+		t.Error("expected an error for two YAVG values in one sample")
+	}
+}
+
+func TestFace(t *testing.T) {
+	typ, err := gpmf.ParseType("BBSSSSSBB")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hero8 style face, box scaled by 65535
+	n := &gpmf.Node{
+		KLV: gpmf.KLV{FourCC: []byte("FACE"), Format: '?', Size: 14, Count: 1},
+		Data: []byte{
+			0x01, 0x5A, // version 1, 90% confidence
+			0x00, 0x03, // ID 3
+			0x7F, 0xFF, 0x40, 0x00, 0x20, 0x00, 0x10, 0x00, // x, y, w, h
+			0x32, 0x00, // 50% smile, no blink
+		},
+	}
+	scale := SCAL{Values: []int{1, 1, 1, 65535, 65535, 65535, 65535, 1, 1}}
+
+	f := FACE{}
+	if err := f.Parse(n, typ, &scale); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Faces) != 1 {
+		t.Fatalf("got %d faces", len(f.Faces))
+	}
+
+	face := f.Faces[0]
+	if face.ID != 3 || face.Confidence != 90 || face.Smile != 50 || math.Abs(face.X-0.5) > 1e-4 {
+		t.Errorf("got %+v", face)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	var file bytes.Buffer
+	enc := gpmf.NewEncoder(&file)
+
+	// something other than a device first, then two one-second payloads
+	enc.Encode(gpmf.NewString("FREE", "xx"))
+	for _, gpsu := range []string{"170301120000.000", "170301120001.000"} {
+		enc.Encode(gpmf.NewContainer("DEVC", gpmf.NewContainer("STRM", gpmf.NewString("GPSU", gpsu))))
+	}
+
+	dec := NewDecoder(iotest.OneByteReader(&file), nil)
+
+	for i := 0; i < 2; i++ {
+		telem, err := dec.Next()
+		if err != nil {
+			t.Fatalf("payload %d: %v", i, err)
+		}
+		if telem.Start != time.Duration(i)*time.Second {
+			t.Errorf("payload %d starts at %v", i, telem.Start)
+		}
+		if telem.Time.Time.Second() != i {
+			t.Errorf("payload %d has GPSU %v", i, telem.Time.Time)
+		}
+	}
+
+	if _, err := dec.Next(); err != io.EOF {
+		t.Errorf("got %v at the end, want io.EOF", err)
+	}
+}
+
+func TestDecoderTruncated(t *testing.T) {
+	gps, _ := gpmf.NewValues("GPS5", 'l', 5, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	devc, _ := gpmf.NewContainer("DEVC",
+		gpmf.NewContainer("STRM", gpmf.NewString("GPSU", "170301120000.000")),
+		gpmf.NewContainer("STRM", gps),
+	).MarshalBinary()
+
+	// the battery died during the GPS
+	dec := NewDecoder(bytes.NewReader(devc[:len(devc)-8]), nil)
+
+	telem, err := dec.Next()
+	if !errors.Is(err, gpmf.ErrTruncated) {
+		t.Fatalf("got %v, want a truncation", err)
+	}
+	if telem == nil || telem.Time.Time.IsZero() {
+		t.Fatal("lost the GPSU before the truncation")
+	}
+	if len(telem.Gps) != 0 {
+		t.Errorf("decoded %d samples of the cut short GPS5", len(telem.Gps))
+	}
+
+	if _, err := dec.Next(); err != io.EOF {
+		t.Errorf("got %v after the truncation, want io.EOF", err)
+	}
+}
+
+func TestDecoderLenient(t *testing.T) {
+	// GPS5 with a structure size no GPS5 sample has
+	bad, _ := gpmf.NewValues("GPS5", 'l', 1, 1, 2, 3, 4, 5)
+	good := gpmf.NewString("GPSU", "170301120000.000")
+
+	var file bytes.Buffer
+	enc := gpmf.NewEncoder(&file)
+	enc.Encode(gpmf.NewContainer("DEVC", gpmf.NewContainer("STRM", bad)))
+	enc.Encode(gpmf.NewContainer("DEVC", gpmf.NewContainer("STRM", good)))
+
+	if _, err := NewDecoder(bytes.NewReader(file.Bytes()), nil).Next(); err == nil {
+		t.Fatal("bad GPS5 decoded")
+	}
+
+	dec := NewDecoder(bytes.NewReader(file.Bytes()), nil)
+	dec.Lenient()
+
+	telem, err := dec.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if telem.Time.Time.IsZero() {
+		t.Error("did not get the second payload")
+	}
+	if _, err := dec.Next(); err != io.EOF {
+		t.Errorf("got %v at the end, want io.EOF", err)
+	}
+
+	if skipped := dec.Skipped(); len(skipped) != 1 || skipped[0].Offset != 0 {
+		t.Errorf("skipped %+v, want the first payload", skipped)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	// ACCL samples of two values instead of three
+	accl, _ := gpmf.NewValues("ACCL", 's', 2, 1, 2, 3, 4)
+	devc, _ := gpmf.NewContainer("DEVC", gpmf.NewContainer("STRM", accl)).MarshalBinary()
+
+	_, err := Read(bytes.NewReader(devc))
+	if !errors.Is(err, ErrInvalidLength) {
+		t.Fatalf("got %v, want an invalid length", err)
+	}
+
+	var e *gpmf.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("%v does not say where it is", err)
+	}
+	if e.Offset != 16 || e.Want != 6 || e.Got != 4 || len(e.Path) != 3 || e.Path[2] != "ACCL" {
+		t.Errorf("got %+v", e)
+	}
+
+	// structures of 6 bytes, laid out as 4, in the second stream: the
+	// error from gpmf keeps its own sizes and gets the element's real path
+	odd := &gpmf.Node{KLV: gpmf.KLV{FourCC: []byte("XYZW"), Format: '?', Size: 6, Count: 1}, Data: make([]byte, 6)}
+	devc, _ = gpmf.NewContainer("DEVC",
+		gpmf.NewContainer("STRM", gpmf.NewString("STNM", "first")),
+		gpmf.NewContainer("STRM", gpmf.NewString("TYPE", "ss"), odd),
+	).MarshalBinary()
+
+	_, err = Read(bytes.NewReader(devc))
+	if !errors.Is(err, gpmf.ErrTypeMismatch) || !errors.As(err, &e) {
+		t.Fatalf("got %v, want a type mismatch", err)
+	}
+	if e.Want != 4 || e.Got != 6 || strings.Join(e.Path, "/") != "DEVC/STRM/XYZW" {
+		t.Errorf("got %+v", e)
+	}
+
+	// quaternions name their stream
+	cori, _ := gpmf.NewValues("CORI", 's', 3, 1, 2, 3)
+	devc, _ = gpmf.NewContainer("DEVC", gpmf.NewContainer("STRM", cori)).MarshalBinary()
+
+	var l *LengthError
+	if _, err = Read(bytes.NewReader(devc)); !errors.As(err, &l) || l.FourCC != "CORI" {
+		t.Errorf("got %v, want a CORI length error", err)
+	}
+
+	// a payload that isn't whole values wants the next whole number of them
+	scal := SCAL{}
+	if err := scal.Parse([]byte{0, 1, 0, 2, 0}, 2); !errors.As(err, &l) || l.Want != 6 || l.Got != 5 {
+		t.Errorf("got %v, want 6 bytes", err)
+	}
+}
+
+func TestDevices(t *testing.T) {
+	device := func(id uint32, name string) *gpmf.Node {
+		dvid, _ := gpmf.NewValues("DVID", 'L', 1, id)
+		return gpmf.NewContainer("DEVC", dvid, gpmf.NewString("DVNM", name))
+	}
+
+	// a second of camera and drone telemetry each, twice
+	var file bytes.Buffer
+	enc := gpmf.NewEncoder(&file)
+	for i := 0; i < 2; i++ {
+		enc.Encode(device(1, "Camera"))
+		enc.Encode(device(2, "Karma"))
+	}
+
+	dec := NewDecoder(bytes.NewReader(file.Bytes()), nil)
+	for i := 0; i < 4; i++ {
+		telem, err := dec.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if telem.Device.ID != uint32(1+i%2) || telem.Start != time.Duration(i/2)*time.Second {
+			t.Errorf("payload %d from %v at %v", i, telem.Device, telem.Start)
+		}
+	}
+
+	dec = NewDecoder(bytes.NewReader(file.Bytes()), nil)
+	dec.Select("karma")
+	for i := 0; i < 2; i++ {
+		telem, err := dec.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if telem.Device.Name != "Karma" {
+			t.Errorf("selected %v", telem.Device)
+		}
+	}
+	if _, err := dec.Next(); err != io.EOF {
+		t.Errorf("got %v at the end, want io.EOF", err)
+	}
+}
+
+func TestStreamInfo(t *testing.T) {
+	accl, _ := gpmf.NewValues("ACCL", 's', 3, 1, 2, 3)
+	siun, _ := gpmf.NewStrings("SIUN", "m/s\xb2")
+	devc, _ := gpmf.NewContainer("DEVC", gpmf.NewContainer("STRM",
+		gpmf.NewString("STNM", "Accelerometer"), siun, accl,
+	)).MarshalBinary()
+
+	telem, err := Read(bytes.NewReader(devc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, ok := telem.Streams["ACCL"]
+	if !ok || info.Name != "Accelerometer" {
+		t.Fatalf("ACCL described as %+v", info)
+	}
+	if len(info.SIUnits) != 1 || info.SIUnits[0] != "m/s²" {
+		t.Errorf("ACCL in %q", info.SIUnits)
+	}
+}
+
+func TestTracker(t *testing.T) {
+	// heading east at 5 m/s
+	payload := func(lon float64) *TELEM {
+		return &TELEM{Gps: []GPS5{{Longitude: lon, Speed: 5}, {Longitude: lon + 0.001, Speed: 5}}}
+	}
+
+	// files tracked side by side don't disturb each other
+	tracks := make(chan float64, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			tr := NewTracker()
+			tr.Rows(payload(0))
+			tracks <- tr.Rows(payload(0.002))[0].Track
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if track := <-tracks; math.Abs(track-90) > 1e-6 {
+			t.Errorf("second payload tracked at %v, want 90", track)
+		}
+	}
+
+	// nor does the file before: the first row heads for the second
+	if track := NewTracker().Rows(payload(0))[0].Track; math.Abs(track-90) > 1e-6 {
+		t.Errorf("first row tracked at %v, want 90", track)
+	}
+
+	// nor does another device in the same file, here a drone to the north
+	// heading north while the camera heads east
+	drone := func(lat float64) *TELEM {
+		return &TELEM{Device: DVID{ID: 2}, Gps: []GPS5{{Latitude: lat, Speed: 5}, {Latitude: lat + 0.001, Speed: 5}}}
+	}
+	tr := NewTracker()
+	tr.Rows(payload(0))
+	tr.Rows(drone(1))
+	if track := tr.Rows(payload(0.002))[0].Track; math.Abs(track-90) > 1e-6 {
+		t.Errorf("camera tracked at %v after the drone, want 90", track)
+	}
+	if track := tr.Rows(drone(1.002))[0].Track; math.Abs(track) > 1e-6 && math.Abs(track-360) > 1e-6 {
+		t.Errorf("drone tracked at %v after the camera, want 0", track)
+	}
+}
+
+func TestDecoderChapters(t *testing.T) {
+	// a second of ACCL per payload, TSMP counting each chapter from zero
+	chapter := func(payloads int) *bytes.Buffer {
+		var file bytes.Buffer
+		enc := gpmf.NewEncoder(&file)
+		for i := 1; i <= payloads; i++ {
+			accl, _ := gpmf.NewValues("ACCL", 's', 3, 1, 2, 3, 4, 5, 6)
+			tsmp, _ := gpmf.NewValues("TSMP", 'L', 1, uint32(2*i))
+			enc.Encode(gpmf.NewContainer("DEVC", gpmf.NewContainer("STRM", tsmp, accl)))
+		}
+		return &file
+	}
+
+	dec := NewDecoder(chapter(2), nil)
+	dec.Append(chapter(2), nil)
+
+	for i := 0; i < 4; i++ {
+		telem, err := dec.Next()
+		if err != nil {
+			t.Fatalf("payload %d: %v", i, err)
+		}
+		if telem.Start != time.Duration(i)*time.Second {
+			t.Errorf("payload %d starts at %v", i, telem.Start)
+		}
+		if telem.Totals["ACCL"] != uint32(2*(i+1)) {
+			t.Errorf("payload %d has %d ACCL samples so far", i, telem.Totals["ACCL"])
+		}
+	}
+
+	if _, err := dec.Next(); err != io.EOF {
+		t.Errorf("got %v at the end, want io.EOF", err)
+	}
+}
+
+func TestDecoderChapterTracks(t *testing.T) {
+	// payloads of 2 and 4 ACCL samples, so of different sizes, and a track
+	// timing them at the given milliseconds, in the order they're given
+	chapter := func(times ...uint64) (*bytes.Buffer, *mp4.Track) {
+		var file bytes.Buffer
+		enc := gpmf.NewEncoder(&file)
+		track := &mp4.Track{Timescale: 1000}
+		for i, ms := range times {
+			var samples []interface{}
+			for j := 0; j < 6*(i%2+1); j++ {
+				samples = append(samples, int16(j))
+			}
+			accl, _ := gpmf.NewValues("ACCL", 's', 3, samples...)
+			devc := gpmf.NewContainer("DEVC", gpmf.NewContainer("STRM", accl))
+			payload, _ := devc.MarshalBinary()
+			track.Samples = append(track.Samples, mp4.Sample{Size: int64(len(payload)), Time: ms, Duration: 1001})
+			if i < 2 {
+				enc.Encode(devc)
+			}
+		}
+		return &file, track
+	}
+
+	// the first chapter's track has a third sample, cut from the stream,
+	// which the second chapter still starts after
+	first, firstTrack := chapter(0, 1001, 2002)
+	second, secondTrack := chapter(0, 1001)
+
+	dec := NewDecoder(first, firstTrack)
+	dec.Append(second, secondTrack)
+
+	for i, want := range []time.Duration{0, 1001, 3003, 4004} {
+		telem, err := dec.Next()
+		if err != nil {
+			t.Fatalf("payload %d: %v", i, err)
+		}
+		if telem.Start != want*time.Millisecond || telem.Duration != 1001*time.Millisecond {
+			t.Errorf("payload %d at %v for %v, want %v", i, telem.Start, telem.Duration, want*time.Millisecond)
+		}
+		if len(telem.Accl) != 2*(i%2+1) {
+			t.Errorf("payload %d has %d ACCL samples", i, len(telem.Accl))
+		}
+	}
+
+	if _, err := dec.Next(); err != io.EOF {
+		t.Errorf("got %v at the end, want io.EOF", err)
+	}
+}
@@ -0,0 +1,130 @@
+package telemetry
+
+import (
+	"time"
+)
+
+// Stamp - give every sample of the sensor, GPS and image streams its time
+// since the start of the video, and a UTC time when the payload has a GPSU
+// (GPS9 keeps its own). start and duration are the payload's presentation
+// time and length, eg. from mp4.Track.
+func (t *TELEM) Stamp(start, duration time.Duration) {
+	t.Start = start
+	t.Duration = duration
+
+	t.stamp("ACCL", len(t.Accl), func(i int, mt time.Duration, ts int64) {
+		t.Accl[i].MediaTime, t.Accl[i].TS = mt, ts
+	})
+	t.stamp("GYRO", len(t.Gyro), func(i int, mt time.Duration, ts int64) {
+		t.Gyro[i].MediaTime, t.Gyro[i].TS = mt, ts
+	})
+	t.stamp("MAGN", len(t.Magn), func(i int, mt time.Duration, ts int64) {
+		t.Magn[i].MediaTime, t.Magn[i].TS = mt, ts
+	})
+	t.stamp("CORI", len(t.Cori), func(i int, mt time.Duration, ts int64) {
+		t.Cori[i].MediaTime, t.Cori[i].TS = mt, ts
+	})
+	t.stamp("IORI", len(t.Iori), func(i int, mt time.Duration, ts int64) {
+		t.Iori[i].MediaTime, t.Iori[i].TS = mt, ts
+	})
+	t.stamp("GRAV", len(t.Grav), func(i int, mt time.Duration, ts int64) {
+		t.Grav[i].MediaTime, t.Grav[i].TS = mt, ts
+	})
+	t.stamp("SHUT", len(t.Shut), func(i int, mt time.Duration, ts int64) {
+		t.Shut[i].MediaTime, t.Shut[i].TS = mt, ts
+	})
+	t.stamp("ISOG", len(t.Isog), func(i int, mt time.Duration, ts int64) {
+		t.Isog[i].MediaTime, t.Isog[i].TS = mt, ts
+	})
+	t.stamp("ISOE", len(t.Isoe), func(i int, mt time.Duration, ts int64) {
+		t.Isoe[i].MediaTime, t.Isoe[i].TS = mt, ts
+	})
+	t.stamp("WBAL", len(t.Wbal), func(i int, mt time.Duration, ts int64) {
+		t.Wbal[i].MediaTime, t.Wbal[i].TS = mt, ts
+	})
+	t.stamp("WRGB", len(t.Wrgb), func(i int, mt time.Duration, ts int64) {
+		t.Wrgb[i].MediaTime, t.Wrgb[i].TS = mt, ts
+	})
+	t.stamp("YAVG", len(t.Yavg), func(i int, mt time.Duration, ts int64) {
+		t.Yavg[i].MediaTime, t.Yavg[i].TS = mt, ts
+	})
+	t.stamp("UNIF", len(t.Unif), func(i int, mt time.Duration, ts int64) {
+		t.Unif[i].MediaTime, t.Unif[i].TS = mt, ts
+	})
+	t.stamp("HUES", len(t.Hues), func(i int, mt time.Duration, ts int64) {
+		t.Hues[i].MediaTime, t.Hues[i].TS = mt, ts
+	})
+	t.stamp("SCEN", len(t.Scen), func(i int, mt time.Duration, ts int64) {
+		t.Scen[i].MediaTime, t.Scen[i].TS = mt, ts
+	})
+	t.stamp("FACE", len(t.Face), func(i int, mt time.Duration, ts int64) {
+		t.Face[i].MediaTime, t.Face[i].TS = mt, ts
+	})
+	t.stamp("FCNM", len(t.Fcnm), func(i int, mt time.Duration, ts int64) {
+		t.Fcnm[i].MediaTime, t.Fcnm[i].TS = mt, ts
+	})
+	t.stamp("GPS5", len(t.Gps), func(i int, mt time.Duration, ts int64) {
+		t.Gps[i].MediaTime, t.Gps[i].TS = mt, ts
+	})
+	t.stamp("GPS9", len(t.Gps9), func(i int, mt time.Duration, ts int64) {
+		t.Gps9[i].MediaTime = mt
+	})
+}
+
+// stamp - hand the media and UTC time of each of the n samples of a stream
+// to set
+func (t *TELEM) stamp(key string, n int, set func(i int, mt time.Duration, ts int64)) {
+	for i, mt := range t.sampleTimes(key, n) {
+		set(i, mt, t.utc(mt))
+	}
+}
+
+// sampleTimes - media time of each of the n samples of a stream in this
+// payload.
+//
+// TSMP counts every sample the stream has delivered up to the end of this
+// payload, so dividing it by the payload's end time gives the stream's real
+// sample rate and places the samples without assuming they fill the payload
+// exactly. When there is no count, or the count doesn't line up with the
+// media (eg. a later chapter of a split recording), the samples are spread
+// evenly over the payload instead.
+func (t *TELEM) sampleTimes(key string, n int) []time.Duration {
+	times := make([]time.Duration, n)
+	if n == 0 {
+		return times
+	}
+
+	end := t.Start + t.Duration
+	total := int64(t.Totals[key])
+	first := total - int64(n)
+
+	at := func(i int) time.Duration {
+		return time.Duration(float64(first+int64(i)) / float64(total) * float64(end))
+	}
+
+	useCount := total > 0 && first >= 0 && end > 0
+	if useCount {
+		t0 := at(0)
+		useCount = t0 >= t.Start-t.Duration && t0 <= t.Start+t.Duration
+	}
+
+	for i := range times {
+		if useCount {
+			times[i] = at(i)
+		} else {
+			times[i] = t.Start + t.Duration*time.Duration(i)/time.Duration(n)
+		}
+	}
+
+	return times
+}
+
+// utc - the UTC time in microseconds of a sample at media time mt, anchoring
+// the payload's GPSU to its start
+func (t *TELEM) utc(mt time.Duration) int64 {
+	if t.Time.Time.IsZero() {
+		return 0
+	}
+
+	return t.Time.Time.Add(mt-t.Start).UnixNano() / 1000
+}
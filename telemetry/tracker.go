@@ -0,0 +1,100 @@
+package telemetry
+
+import (
+	"github.com/paulmach/go.geo"
+)
+
+// Tracker - turns the GPS of one file's payloads, in order, into output rows
+// with the direction of travel. Each device in the file, eg. the camera and a
+// drone, is tracked on its own. Each file needs its own Tracker; separate
+// Trackers can be used from separate goroutines.
+type Tracker struct {
+	devices map[uint32]*course // by DVID
+}
+
+// course - where one device has been going
+type course struct {
+	prev *geo.Point // the last row's position, nil before the first
+	last float64    // the last track while moving
+}
+
+// NewTracker - tracker for a new file
+func NewTracker() *Tracker {
+	return &Tracker{devices: map[uint32]*course{}}
+}
+
+// Rows - the GPS rows of the next payload
+func (tr *Tracker) Rows(t *TELEM) []TELEM_OUT {
+	var out []TELEM_OUT
+
+	c := tr.devices[t.Device.ID]
+	if c == nil {
+		c = &course{}
+		tr.devices[t.Device.ID] = c
+	}
+
+	// prefer GPS9 when the camera has it
+	gps := t.Gps
+	if len(t.Gps9) > 0 {
+		gps = make([]GPS5, len(t.Gps9))
+		for i, g := range t.Gps9 {
+			gps[i] = GPS5{
+				Latitude:  g.Latitude,
+				Longitude: g.Longitude,
+				Altitude:  g.Altitude,
+				Speed:     g.Speed,
+				Speed3D:   g.Speed3D,
+				TS:        g.TS,
+				MediaTime: g.MediaTime,
+			}
+		}
+	}
+
+	for i, _ := range gps {
+		jobj := TELEM_OUT{GPS5: &gps[i]}
+		if len(t.Gps9) > 0 {
+			// every GPS9 sample has its own fix and precision (DOP x100, as GPSP)
+			jobj.GpsAccuracy = uint16(t.Gps9[i].DOP * 100)
+			jobj.GpsFix = uint32(t.Gps9[i].Fix)
+		} else if 0 == i {
+			jobj.GpsAccuracy = t.GpsAccuracy.Accuracy
+			jobj.GpsFix = t.GpsFix.F
+		}
+		if 0 == i {
+			jobj.Temp = t.Temp.Temp
+		}
+
+		// the very first row heads for the one after it
+		p := geo.NewPoint(jobj.GPS5.Longitude, jobj.GPS5.Latitude)
+		if c.prev != nil {
+			jobj.Track = c.prev.BearingTo(p)
+		} else if i+1 < len(gps) {
+			jobj.Track = p.BearingTo(geo.NewPoint(gps[i+1].Longitude, gps[i+1].Latitude))
+		}
+		c.prev = p
+
+		if jobj.Track < 0 {
+			jobj.Track = 360 + jobj.Track
+		}
+
+		heading, hasHeading := t.HeadingAt(jobj.GPS5.MediaTime)
+		if hasHeading {
+			jobj.Heading = &heading
+		}
+
+		// only set the track if speed is over 1 m/s
+		// if it's slower (eg, stopped) it will drift all over with the location,
+		// so use the compass if there is one
+		if jobj.GPS5.Speed > 1 {
+			c.last = jobj.Track
+		} else if hasHeading {
+			jobj.Track = heading
+		} else {
+			jobj.Track = c.last
+		}
+
+		out = append(out, jobj)
+	}
+
+	return out
+}
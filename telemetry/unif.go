@@ -0,0 +1,27 @@
+package telemetry
+
+import (
+	"time"
+)
+
+// Uniformity of a frame, 0 to 1 where 1 is a solid colour
+type UNIF struct {
+	Uniformity float64 `json:"uniformity"`
+
+	MediaTime time.Duration `json:"media_time"` // since the start of the video
+	TS        int64         `json:"utc"`        // UTC in microseconds, zero without a GPS time
+}
+
+func (unif *UNIF) Parse(bytes []byte, format byte, scale *SCAL) error {
+	v, err := numbers(format, bytes, scale)
+	if err != nil {
+		return err
+	}
+	if 1 != len(v) {
+		return &LengthError{FourCC: "UNIF", Want: formatSize(format), Got: len(bytes)}
+	}
+
+	unif.Uniformity = v[0]
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package telemetry
+
+import (
+	"time"
+)
+
+// White balance of a frame in Kelvin
+type WBAL struct {
+	Kelvin float64 `json:"kelvin"`
+
+	MediaTime time.Duration `json:"media_time"` // since the start of the video
+	TS        int64         `json:"utc"`        // UTC in microseconds, zero without a GPS time
+}
+
+func (wbal *WBAL) Parse(bytes []byte, format byte, scale *SCAL) error {
+	v, err := numbers(format, bytes, scale)
+	if err != nil {
+		return err
+	}
+	if 1 != len(v) {
+		return &LengthError{FourCC: "WBAL", Want: formatSize(format), Got: len(bytes)}
+	}
+
+	wbal.Kelvin = v[0]
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package telemetry
+
+import (
+	"time"
+)
+
+// White balance RGB gains of a frame
+type WRGB struct {
+	R float64 `json:"r"`
+	G float64 `json:"g"`
+	B float64 `json:"b"`
+
+	MediaTime time.Duration `json:"media_time"` // since the start of the video
+	TS        int64         `json:"utc"`        // UTC in microseconds, zero without a GPS time
+}
+
+func (wrgb *WRGB) Parse(bytes []byte, format byte, scale *SCAL) error {
+	v, err := numbers(format, bytes, scale)
+	if err != nil {
+		return err
+	}
+	if 3 != len(v) {
+		return &LengthError{FourCC: "WRGB", Want: 3 * formatSize(format), Got: len(bytes)}
+	}
+
+	wrgb.R, wrgb.G, wrgb.B = v[0], v[1], v[2]
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package telemetry
+
+import (
+	"time"
+)
+
+// Average luma (Y) of a frame, 0 (black) to 255 (white)
+type YAVG struct {
+	Luma float64 `json:"luma"`
+
+	MediaTime time.Duration `json:"media_time"` // since the start of the video
+	TS        int64         `json:"utc"`        // UTC in microseconds, zero without a GPS time
+}
+
+func (yavg *YAVG) Parse(bytes []byte, format byte, scale *SCAL) error {
+	v, err := numbers(format, bytes, scale)
+	if err != nil {
+		return err
+	}
+	if 1 != len(v) {
+		return &LengthError{FourCC: "YAVG", Want: formatSize(format), Got: len(bytes)}
+	}
+
+	yavg.Luma = v[0]
+
+	return nil
+}